@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cli/go-gh/v2/pkg/tableprinter"
+	"github.com/mattn/go-isatty"
+	"github.com/pomdtr/arc/sidebar"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+type ArchivedTab struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title"`
+	URL        string    `json:"url"`
+	Space      string    `json:"space"`
+	SpaceID    string    `json:"spaceId"`
+	Reason     string    `json:"reason"`
+	ArchivedAt time.Time `json:"archivedAt"`
+}
+
+type archiveEntry struct {
+	SidebarItem sidebar.Item               `json:"sidebarItem"`
+	ArchivedAt  float64                    `json:"archivedAt"`
+	Reason      map[string]json.RawMessage `json:"reason"`
+	Source      struct {
+		Space *struct {
+			ID string `json:"_0"`
+		} `json:"space"`
+	} `json:"source"`
+}
+
+func loadArchive() ([]ArchivedTab, error) {
+	path, err := dataPath("StorableArchive.json")
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive file: %w", err)
+	}
+
+	var storable struct {
+		Items []json.RawMessage `json:"items"`
+	}
+	if err := json.Unmarshal(content, &storable); err != nil {
+		return nil, fmt.Errorf("failed to parse archive file: %w", err)
+	}
+
+	entries, err := sidebar.DecodeObjects[archiveEntry](storable.Items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse archive file: %w", err)
+	}
+
+	// the sidebar is only needed to name spaces, the archive is still
+	// readable without it
+	spaceTitles := make(map[string]string)
+	if s, err := loadSidebar(); err == nil {
+		for _, space := range s.Spaces() {
+			spaceTitles[space.ID] = space.Title
+		}
+	}
+
+	var tabs []ArchivedTab
+	for _, entry := range entries {
+		if entry.SidebarItem.Type() != "tab" {
+			continue
+		}
+
+		tab := ArchivedTab{
+			ID:         entry.SidebarItem.ID,
+			Title:      entry.SidebarItem.DisplayTitle(),
+			URL:        entry.SidebarItem.URL(),
+			ArchivedAt: sidebar.AppleTime(entry.ArchivedAt),
+		}
+
+		if entry.Source.Space != nil {
+			tab.SpaceID = entry.Source.Space.ID
+			tab.Space = spaceTitles[tab.SpaceID]
+		}
+
+		for reason := range entry.Reason {
+			tab.Reason = reason
+		}
+
+		tabs = append(tabs, tab)
+	}
+
+	sort.SliceStable(tabs, func(i, j int) bool {
+		return tabs[i].ArchivedAt.After(tabs[j].ArchivedAt)
+	})
+
+	return tabs, nil
+}
+
+// matchDomain reports whether the host of rawURL is domain or one of its
+// subdomains.
+func matchDomain(rawURL string, domain string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	domain = strings.ToLower(domain)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+type archiveFilters struct {
+	timeRange
+	Space  string
+	Domain string
+}
+
+func (f archiveFilters) apply(tabs []ArchivedTab) ([]ArchivedTab, error) {
+	since, until, err := f.bounds()
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []ArchivedTab
+	for _, tab := range tabs {
+		if f.Space != "" && !strings.EqualFold(tab.Space, f.Space) && tab.SpaceID != f.Space {
+			continue
+		}
+
+		if f.Domain != "" && !matchDomain(tab.URL, f.Domain) {
+			continue
+		}
+
+		if !since.IsZero() && tab.ArchivedAt.Before(since) {
+			continue
+		}
+
+		if !until.IsZero() && !tab.ArchivedAt.Before(until) {
+			continue
+		}
+
+		filtered = append(filtered, tab)
+	}
+
+	return filtered, nil
+}
+
+func (f *archiveFilters) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.Space, "space", "", "only show tabs archived from this space")
+	cmd.Flags().StringVar(&f.Domain, "domain", "", "only show tabs from this domain")
+	f.timeRange.register(cmd)
+}
+
+func printArchivedTabs(tabs []ArchivedTab, asJson bool) error {
+	if asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(tabs)
+	}
+
+	var printer tableprinter.TablePrinter
+	if !isatty.IsTerminal(os.Stdout.Fd()) {
+		printer = tableprinter.New(os.Stdout, false, 0)
+	} else {
+		w, _, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			return err
+		}
+
+		printer = tableprinter.New(os.Stdout, true, w)
+	}
+
+	printer.AddHeader([]string{"ID", "Space", "ArchivedAt", "Title", "URL"})
+	for _, tab := range tabs {
+		printer.AddField(tab.ID)
+		printer.AddField(tab.Space)
+		printer.AddField(tab.ArchivedAt.Local().Format(time.DateTime))
+		printer.AddField(tab.Title)
+		printer.AddField(tab.URL)
+		printer.EndRow()
+	}
+
+	return printer.Render()
+}
+
+func NewCmdArchive() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "archive",
+		Short: "Browse archived tabs",
+	}
+
+	cmd.AddCommand(NewCmdArchiveList())
+	cmd.AddCommand(NewCmdArchiveSearch())
+	cmd.AddCommand(NewCmdArchiveRestore())
+
+	return cmd
+}
+
+func NewCmdArchiveList() *cobra.Command {
+	var flags struct {
+		archiveFilters
+		Json bool
+	}
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List archived tabs",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tabs, err := loadArchive()
+			if err != nil {
+				return err
+			}
+
+			tabs, err = flags.apply(tabs)
+			if err != nil {
+				return err
+			}
+
+			return printArchivedTabs(tabs, flags.Json)
+		},
+	}
+
+	flags.register(cmd)
+	cmd.Flags().BoolVar(&flags.Json, "json", false, "output as json")
+	return cmd
+}
+
+func NewCmdArchiveSearch() *cobra.Command {
+	var flags struct {
+		archiveFilters
+		Json bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "search <query>",
+		Short: "Search archived tabs by title or url",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tabs, err := loadArchive()
+			if err != nil {
+				return err
+			}
+
+			tabs, err = flags.apply(tabs)
+			if err != nil {
+				return err
+			}
+
+			query := strings.ToLower(args[0])
+			var matches []ArchivedTab
+			for _, tab := range tabs {
+				if strings.Contains(strings.ToLower(tab.Title), query) || strings.Contains(strings.ToLower(tab.URL), query) {
+					matches = append(matches, tab)
+				}
+			}
+
+			return printArchivedTabs(matches, flags.Json)
+		},
+	}
+
+	flags.register(cmd)
+	cmd.Flags().BoolVar(&flags.Json, "json", false, "output as json")
+	return cmd
+}
+
+func NewCmdArchiveRestore() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore <id>",
+		Short: "Reopen an archived tab in its original space",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tabs, err := loadArchive()
+			if err != nil {
+				return err
+			}
+
+			tab, err := findArchivedTab(tabs, args[0])
+			if err != nil {
+				return err
+			}
+
+			space, err := resolveSpace(tab.Space)
+			if err != nil {
+				return err
+			}
+
+			return createTabInSpace(tab.URL, space.ID)
+		},
+	}
+
+	return cmd
+}
+
+// findArchivedTab returns the archived tab with an id, which can only be
+// restored when the space it was archived from is still known.
+func findArchivedTab(tabs []ArchivedTab, id string) (ArchivedTab, error) {
+	for _, tab := range tabs {
+		if tab.ID != id {
+			continue
+		}
+
+		if tab.Space == "" {
+			return ArchivedTab{}, fmt.Errorf("original space of tab %s is unknown", tab.ID)
+		}
+
+		return tab, nil
+	}
+
+	return ArchivedTab{}, fmt.Errorf("archived tab not found: %s", id)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoadArchive(t *testing.T) {
+	dir := t.TempDir()
+	for src, dst := range map[string]string{
+		"testdata/StorableArchive.json":         "StorableArchive.json",
+		"sidebar/testdata/StorableSidebar.json": "StorableSidebar.json",
+	} {
+		content, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, dst), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	useDataDir(t, dir)
+
+	tabs, err := loadArchive()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// folders are skipped and tabs are sorted from the last archived
+	want := []ArchivedTab{
+		{ID: "A3", Title: "Example", URL: "https://example.com/", SpaceID: "S9", Reason: "auto"},
+		{ID: "A5", Title: "GitHub", URL: "https://github.com/", Reason: "manual"},
+		{ID: "A2", Title: "Renamed", URL: "https://docs.github.com/en", Space: "Work", SpaceID: "S2", Reason: "manual"},
+		{ID: "A1", Title: "Attention Is All You Need", URL: "https://arxiv.org/abs/1706.03762", Space: "Research", SpaceID: "S1", Reason: "auto"},
+	}
+
+	if len(tabs) != len(want) {
+		t.Fatalf("got %d tabs, want %d: %+v", len(tabs), len(want), tabs)
+	}
+
+	for i, tab := range tabs {
+		if tab.ArchivedAt.IsZero() {
+			t.Errorf("tab %s has no archive date", tab.ID)
+		}
+
+		tab.ArchivedAt = want[i].ArchivedAt
+		if tab != want[i] {
+			t.Errorf("tab %d = %+v, want %+v", i, tab, want[i])
+		}
+	}
+}
+
+func TestArchiveFilters(t *testing.T) {
+	tabs := []ArchivedTab{
+		{ID: "A1", URL: "https://arxiv.org/abs/1", Space: "Research", SpaceID: "S1", ArchivedAt: time.Date(2023, 3, 8, 12, 0, 0, 0, time.UTC)},
+		{ID: "A2", URL: "https://docs.github.com/en", Space: "Work", SpaceID: "S2", ArchivedAt: time.Date(2023, 7, 2, 12, 0, 0, 0, time.UTC)},
+		{ID: "A3", URL: "https://notgithub.com/", SpaceID: "S9", ArchivedAt: time.Date(2023, 10, 26, 12, 0, 0, 0, time.UTC)},
+		{ID: "A4", URL: "https://GitHub.com/", ArchivedAt: time.Date(2023, 8, 29, 12, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range []struct {
+		Name    string
+		Filters archiveFilters
+		Want    []string
+	}{
+		{"none", archiveFilters{}, []string{"A1", "A2", "A3", "A4"}},
+		{"space title", archiveFilters{Space: "research"}, []string{"A1"}},
+		{"space id", archiveFilters{Space: "S9"}, []string{"A3"}},
+		{"domain", archiveFilters{Domain: "github.com"}, []string{"A2", "A4"}},
+		{"since", archiveFilters{timeRange: timeRange{Since: "2023-08-01T00:00:00Z"}}, []string{"A3", "A4"}},
+		{"until", archiveFilters{timeRange: timeRange{Until: "2023-07-02T12:00:00Z"}}, []string{"A1"}},
+		{"domain and range", archiveFilters{Domain: "github.com", timeRange: timeRange{Since: "2023-07-01T00:00:00Z", Until: "2023-08-01T00:00:00Z"}}, []string{"A2"}},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			filtered, err := tc.Filters.apply(tabs)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var ids []string
+			for _, tab := range filtered {
+				ids = append(ids, tab.ID)
+			}
+
+			if !slices.Equal(ids, tc.Want) {
+				t.Errorf("got %v, want %v", ids, tc.Want)
+			}
+		})
+	}
+}
+
+func TestFindArchivedTab(t *testing.T) {
+	tabs := []ArchivedTab{
+		{ID: "A1", URL: "https://arxiv.org/", Space: "Research", SpaceID: "S1"},
+		{ID: "A3", URL: "https://example.com/", SpaceID: "S9"},
+	}
+
+	tab, err := findArchivedTab(tabs, "A1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tab.Space != "Research" {
+		t.Errorf("restored to %q, want Research", tab.Space)
+	}
+
+	// the space of A3 was deleted since
+	for _, id := range []string{"A3", "A9"} {
+		if _, err := findArchivedTab(tabs, id); err == nil {
+			t.Errorf("restoring %s succeeded, want an error", id)
+		}
+	}
+}
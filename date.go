@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	sb "github.com/huandu/go-sqlbuilder"
+	"github.com/spf13/cobra"
+)
+
+var (
+	shortDurationPattern = regexp.MustCompile(`^(\d+)\s*(s|m|h|d|w)$`)
+	agoPattern           = regexp.MustCompile(`^(\d+)\s*(second|minute|hour|day|week|month|year)s?\s+ago$`)
+)
+
+// parseTime accepts RFC3339 timestamps, plain dates interpreted in the local
+// timezone, and relative expressions such as "2h", "3 days ago", "yesterday"
+// or "last monday".
+func parseTime(value string) (time.Time, error) {
+	return parseTimeAt(value, time.Now())
+}
+
+func parseTimeAt(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	expr := strings.ToLower(strings.TrimSpace(value))
+	today := startOfDay(now)
+	switch expr {
+	case "now":
+		return now, nil
+	case "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	case "last week":
+		return today.AddDate(0, 0, -7), nil
+	case "last month":
+		return today.AddDate(0, -1, 0), nil
+	case "last year":
+		return today.AddDate(-1, 0, 0), nil
+	}
+
+	if match := shortDurationPattern.FindStringSubmatch(expr); match != nil {
+		n, _ := strconv.Atoi(match[1])
+		switch match[2] {
+		case "s":
+			return now.Add(-time.Duration(n) * time.Second), nil
+		case "m":
+			return now.Add(-time.Duration(n) * time.Minute), nil
+		case "h":
+			return now.Add(-time.Duration(n) * time.Hour), nil
+		case "d":
+			return now.AddDate(0, 0, -n), nil
+		case "w":
+			return now.AddDate(0, 0, -7*n), nil
+		}
+	}
+
+	if match := agoPattern.FindStringSubmatch(expr); match != nil {
+		n, _ := strconv.Atoi(match[1])
+		switch match[2] {
+		case "second":
+			return now.Add(-time.Duration(n) * time.Second), nil
+		case "minute":
+			return now.Add(-time.Duration(n) * time.Minute), nil
+		case "hour":
+			return now.Add(-time.Duration(n) * time.Hour), nil
+		case "day":
+			return now.AddDate(0, 0, -n), nil
+		case "week":
+			return now.AddDate(0, 0, -7*n), nil
+		case "month":
+			return now.AddDate(0, -n, 0), nil
+		case "year":
+			return now.AddDate(-n, 0, 0), nil
+		}
+	}
+
+	// "monday" and "last monday" both refer to the most recent monday
+	// before today
+	weekday := strings.TrimPrefix(expr, "last ")
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.ToLower(day.String()) != weekday {
+			continue
+		}
+
+		offset := int(today.Weekday()-day+7) % 7
+		if offset == 0 {
+			offset = 7
+		}
+
+		return today.AddDate(0, 0, -offset), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date: %s", value)
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+type timeRange struct {
+	Since string
+	Until string
+	On    string
+}
+
+func (r *timeRange) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&r.Since, "since", "", "only include entries after this date")
+	cmd.Flags().StringVar(&r.Until, "until", "", "only include entries before this date")
+	cmd.Flags().StringVar(&r.On, "on", "", "only include entries on this day")
+}
+
+// bounds resolves the range, leaving a bound zero when it is open.
+func (r timeRange) bounds() (since time.Time, until time.Time, err error) {
+	if r.On != "" && (r.Since != "" || r.Until != "") {
+		return time.Time{}, time.Time{}, errors.New("--on cannot be combined with --since or --until")
+	}
+
+	if r.On != "" {
+		day, err := parseTime(r.On)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+
+		since = startOfDay(day.Local())
+		until = since.AddDate(0, 0, 1)
+	}
+
+	if r.Since != "" {
+		if since, err = parseTime(r.Since); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	if r.Until != "" {
+		if until, err = parseTime(r.Until); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	return since, until, nil
+}
+
+// where restricts a query on a Chromium timestamp column to the range.
+func (r timeRange) where(query *sb.SelectBuilder, column string) error {
+	since, until, err := r.bounds()
+	if err != nil {
+		return err
+	}
+
+	if !since.IsZero() {
+		query.Where(query.GreaterEqualThan(column, toChromeTime(since)))
+	}
+
+	if !until.IsZero() {
+		query.Where(query.LessThan(column, toChromeTime(until)))
+	}
+
+	return nil
+}
+
+// loadLocation resolves a timezone name, accepting "local" in any case.
+func loadLocation(name string) (*time.Location, error) {
+	if strings.EqualFold(name, "local") {
+		return time.Local, nil
+	}
+
+	return time.LoadLocation(name)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeAt(t *testing.T) {
+	// a wednesday
+	now := time.Date(2024, 5, 15, 14, 30, 0, 0, time.Local)
+
+	for _, tt := range []struct {
+		value string
+		want  time.Time
+	}{
+		{"now", now},
+		{"2h", now.Add(-2 * time.Hour)},
+		{"30m", now.Add(-30 * time.Minute)},
+		{"2w", time.Date(2024, 5, 1, 14, 30, 0, 0, time.Local)},
+		{"today", time.Date(2024, 5, 15, 0, 0, 0, 0, time.Local)},
+		{"yesterday", time.Date(2024, 5, 14, 0, 0, 0, 0, time.Local)},
+		{"Yesterday", time.Date(2024, 5, 14, 0, 0, 0, 0, time.Local)},
+		{"last week", time.Date(2024, 5, 8, 0, 0, 0, 0, time.Local)},
+		{"last month", time.Date(2024, 4, 15, 0, 0, 0, 0, time.Local)},
+		{"3 days ago", time.Date(2024, 5, 12, 14, 30, 0, 0, time.Local)},
+		{"1 hour ago", now.Add(-time.Hour)},
+		{"2 months ago", time.Date(2024, 3, 15, 14, 30, 0, 0, time.Local)},
+		{"last monday", time.Date(2024, 5, 13, 0, 0, 0, 0, time.Local)},
+		{"monday", time.Date(2024, 5, 13, 0, 0, 0, 0, time.Local)},
+		// the same weekday as today refers to the previous week
+		{"last wednesday", time.Date(2024, 5, 8, 0, 0, 0, 0, time.Local)},
+		{"2024-01-02", time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)},
+		{"2024-01-02 15:04", time.Date(2024, 1, 2, 15, 4, 0, 0, time.Local)},
+		{"2024-01-02T15:04:05", time.Date(2024, 1, 2, 15, 4, 5, 0, time.Local)},
+		{"2024-01-02T15:04:05Z", time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)},
+	} {
+		got, err := parseTimeAt(tt.value, now)
+		if err != nil {
+			t.Errorf("%q: %s", tt.value, err)
+			continue
+		}
+
+		if !got.Equal(tt.want) {
+			t.Errorf("%q = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseTimeAtInvalid(t *testing.T) {
+	now := time.Date(2024, 5, 15, 14, 30, 0, 0, time.Local)
+	for _, value := range []string{"", "soon", "3 fortnights ago", "2024-13-01", "last"} {
+		if got, err := parseTimeAt(value, now); err == nil {
+			t.Errorf("%q = %s, want an error", value, got)
+		}
+	}
+}
+
+func TestTimeRangeBounds(t *testing.T) {
+	for _, r := range []timeRange{
+		{On: "yesterday", Since: "2d"},
+		{On: "yesterday", Until: "today"},
+	} {
+		if _, _, err := r.bounds(); err == nil {
+			t.Errorf("%+v: want an error combining --on with --since or --until", r)
+		}
+	}
+
+	since, until, err := timeRange{On: "2024-01-02"}.bounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local); !since.Equal(want) {
+		t.Errorf("since = %s, want %s", since, want)
+	}
+
+	if want := time.Date(2024, 1, 3, 0, 0, 0, 0, time.Local); !until.Equal(want) {
+		t.Errorf("until = %s, want %s", until, want)
+	}
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cli/go-gh/v2/pkg/tableprinter"
+	sb "github.com/huandu/go-sqlbuilder"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// historyDeletes remove the selected visits and urls along with the rows
+// referencing them, children first. Tables missing from older History
+// databases are skipped.
+var historyDeletes = []struct {
+	Table     string
+	Statement string
+}{
+	{"visit_source", "DELETE FROM visit_source WHERE id IN (SELECT id FROM doomed_visits)"},
+	{"content_annotations", "DELETE FROM content_annotations WHERE visit_id IN (SELECT id FROM doomed_visits)"},
+	{"context_annotations", "DELETE FROM context_annotations WHERE visit_id IN (SELECT id FROM doomed_visits)"},
+	{"visits", "DELETE FROM visits WHERE id IN (SELECT id FROM doomed_visits)"},
+	{"keyword_search_terms", "DELETE FROM keyword_search_terms WHERE url_id IN (SELECT id FROM doomed_urls)"},
+	{"segment_usage", "DELETE FROM segment_usage WHERE segment_id IN (SELECT id FROM segments WHERE url_id IN (SELECT id FROM doomed_urls))"},
+	{"segments", "DELETE FROM segments WHERE url_id IN (SELECT id FROM doomed_urls)"},
+	{"urls", "DELETE FROM urls WHERE id IN (SELECT id FROM doomed_urls)"},
+}
+
+type deleteFilters struct {
+	timeRange
+	Query  string
+	Domain string
+}
+
+func (f *deleteFilters) register(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&f.Query, "query", "q", "", "search query")
+	cmd.Flags().StringVar(&f.Domain, "domain", "", "only delete urls on this domain or its subdomains")
+	f.timeRange.register(cmd)
+}
+
+// selectDoomed fills temporary tables with the urls matching the filters,
+// their visits within the time range and matching the date and transition
+// terms, and the urls left without visits.
+func (f deleteFilters) selectDoomed(tx *sql.Tx) error {
+	if f.Query == "" && f.Domain == "" && f.Since == "" && f.Until == "" && f.On == "" {
+		return errors.New("refusing to delete all history, pass a query, domain or time range")
+	}
+
+	matched := sb.NewSelectBuilder()
+	matched.Select("urls.id")
+	matched.From("urls")
+
+	query := f.Query
+	if f.Domain != "" {
+		query = strings.TrimSpace(query + " site:" + f.Domain)
+	}
+
+	terms, err := parseHistoryQuery(query)
+	if err != nil {
+		return err
+	}
+
+	conditions, err := compileHistoryQuery(terms, matched)
+	if err != nil {
+		return err
+	}
+
+	if len(conditions) > 0 {
+		matched.Where(conditions...)
+	}
+
+	visits := sb.NewSelectBuilder()
+	visits.Select("id", "url")
+	visits.From("visits")
+	visits.Where("url IN (SELECT id FROM matched_urls)")
+	if err := f.timeRange.where(visits, "visits.visit_time"); err != nil {
+		return err
+	}
+
+	partial := f.Since != "" || f.Until != "" || f.On != ""
+	for _, term := range terms {
+		if !isVisitTerm(term) {
+			continue
+		}
+
+		condition, err := visitCondition(term, visits)
+		if err != nil {
+			return err
+		}
+
+		if term.Negate {
+			condition = fmt.Sprintf("NOT (%s)", condition)
+		}
+
+		visits.Where(condition)
+		partial = true
+	}
+
+	// when only some visits are selected, urls are only removed once all
+	// their visits are
+	urls := "SELECT id FROM matched_urls m WHERE NOT EXISTS (SELECT 1 FROM visits v WHERE v.url = m.id AND v.id NOT IN (SELECT id FROM doomed_visits))"
+	if partial {
+		urls += " AND m.id IN (SELECT url FROM doomed_visits)"
+	}
+
+	matchedSQL, matchedArgs := matched.Build()
+	if _, err := tx.Exec("CREATE TEMP TABLE matched_urls AS "+matchedSQL, matchedArgs...); err != nil {
+		return fmt.Errorf("failed to select history: %w", err)
+	}
+
+	visitsSQL, visitsArgs := visits.Build()
+	if _, err := tx.Exec("CREATE TEMP TABLE doomed_visits AS "+visitsSQL, visitsArgs...); err != nil {
+		return fmt.Errorf("failed to select history: %w", err)
+	}
+
+	if _, err := tx.Exec("CREATE TEMP TABLE doomed_urls AS " + urls); err != nil {
+		return fmt.Errorf("failed to select history: %w", err)
+	}
+
+	return nil
+}
+
+type deletePreview struct {
+	URL     string
+	Title   string
+	Visits  int
+	Removed bool
+}
+
+func previewDelete(tx *sql.Tx) ([]deletePreview, error) {
+	rows, err := tx.Query(`SELECT u.url, u.title,
+		(SELECT COUNT(*) FROM doomed_visits d WHERE d.url = u.id) AS visits,
+		u.id IN (SELECT id FROM doomed_urls) AS removed
+		FROM urls u
+		WHERE u.id IN (SELECT id FROM matched_urls) AND (visits > 0 OR removed)
+		ORDER BY u.last_visit_time DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	defer rows.Close()
+
+	var previews []deletePreview
+	for rows.Next() {
+		var preview deletePreview
+		if err := rows.Scan(&preview.URL, &preview.Title, &preview.Visits, &preview.Removed); err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		previews = append(previews, preview)
+	}
+
+	return previews, rows.Err()
+}
+
+// arcRunning reports whether Arc is open, as it keeps the History database
+// locked and would overwrite changes made to it.
+func arcRunning() (bool, error) {
+	lock, err := dataPath("User Data", "SingletonLock")
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := os.Lstat(lock); err == nil {
+		return true, nil
+	}
+
+	if runtime.GOOS != "darwin" {
+		return false, nil
+	}
+
+	output, err := runApplescript(`application "Arc" is running`)
+	if err != nil {
+		return false, err
+	}
+
+	return strings.TrimSpace(string(output)) == "true", nil
+}
+
+// backupHistory writes a consistent copy of a History database to the
+// backups dir, returning its path.
+func backupHistory(db *sql.DB, profile Profile) (string, error) {
+	dir, err := appDataDir()
+	if err != nil {
+		return "", err
+	}
+
+	backupDir := filepath.Join(dir, "backups")
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create backup dir: %w", err)
+	}
+
+	backup := filepath.Join(backupDir, fmt.Sprintf("%s-History-%s.sqlite", profile.Dir, time.Now().Format("20060102-150405.000")))
+	if _, err := db.Exec("VACUUM INTO ?", backup); err != nil {
+		return "", fmt.Errorf("failed to backup history: %w", err)
+	}
+
+	return backup, nil
+}
+
+func NewCmdHistoryDelete() *cobra.Command {
+	var flags struct {
+		deleteFilters
+		yes bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete matching urls and visits from history",
+		Long: `Delete matching urls and visits from history.
+
+Matching rows are only listed unless --yes is passed. Deleting requires
+Arc to be closed, and the History database is backed up first. Copies of
+the history cached by arc are removed along with the rows, but visits
+already copied to the archive by arc history sync are kept there.
+
+Like --since and --until, date and transition terms select the visits
+deleted: a url is only removed once none of its visits are left.
+
+` + historyQueryHelp,
+		Example: `  arc history delete --domain example.com
+  arc history delete -q "title:secret" --since "last week" --yes`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profile, err := selectedProfile()
+			if err != nil {
+				return err
+			}
+
+			historyPath, err := profile.Path("History")
+			if err != nil {
+				return err
+			}
+
+			var db *sql.DB
+			if flags.yes {
+				running, err := arcRunning()
+				if err != nil {
+					return err
+				}
+
+				if running {
+					return errors.New("arc is running, quit it before deleting history")
+				}
+
+				if _, err := os.Stat(historyPath); errors.Is(err, fs.ErrNotExist) {
+					return fmt.Errorf("failed to open db file: %w", err)
+				}
+
+				if db, err = sql.Open("sqlite", historyPath); err != nil {
+					return fmt.Errorf("failed to open db: %w", err)
+				}
+				defer db.Close()
+			} else {
+				var cleanup func()
+				if db, cleanup, err = openSnapshot(historyPath); err != nil {
+					return err
+				}
+				defer cleanup()
+			}
+
+			tx, err := db.Begin()
+			if err != nil {
+				return fmt.Errorf("failed to open db: %w", err)
+			}
+			defer tx.Rollback()
+
+			if err := flags.deleteFilters.selectDoomed(tx); err != nil {
+				return err
+			}
+
+			previews, err := previewDelete(tx)
+			if err != nil {
+				return err
+			}
+
+			var visitCount, urlCount int
+			for _, preview := range previews {
+				visitCount += preview.Visits
+				if preview.Removed {
+					urlCount++
+				}
+			}
+
+			if !flags.yes {
+				var printer tableprinter.TablePrinter
+				if !isatty.IsTerminal(os.Stdout.Fd()) {
+					printer = tableprinter.New(os.Stdout, false, 0)
+				} else {
+					w, _, err := term.GetSize(int(os.Stdout.Fd()))
+					if err != nil {
+						return err
+					}
+
+					printer = tableprinter.New(os.Stdout, true, w)
+				}
+
+				printer.AddHeader([]string{"URL", "Title", "Visits", "Removed"})
+				for _, preview := range previews {
+					printer.AddField(preview.URL)
+					printer.AddField(preview.Title)
+					printer.AddField(strconv.Itoa(preview.Visits))
+					printer.AddField(strconv.FormatBool(preview.Removed))
+					printer.EndRow()
+				}
+
+				if err := printer.Render(); err != nil {
+					return err
+				}
+
+				cmd.Printf("Would delete %d visits and %d urls from %s, run again with --yes to delete them.\n", visitCount, urlCount, profile.Dir)
+				return nil
+			}
+
+			if len(previews) == 0 {
+				cmd.Printf("Nothing to delete from %s.\n", profile.Dir)
+				return nil
+			}
+
+			// the backup is taken before any change, outside of the transaction
+			backup, err := backupHistory(db, profile)
+			if err != nil {
+				return err
+			}
+
+			for _, statement := range historyDeletes {
+				var exists bool
+				if err := tx.QueryRow("SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = ?", statement.Table).Scan(&exists); err != nil {
+					return fmt.Errorf("failed to query: %w", err)
+				}
+
+				if !exists {
+					continue
+				}
+
+				if _, err := tx.Exec(statement.Statement); err != nil {
+					return fmt.Errorf("failed to delete from %s: %w", statement.Table, err)
+				}
+			}
+
+			// urls keeping some of their visits get their counters updated
+			if _, err := tx.Exec(`UPDATE urls SET
+				visit_count = (SELECT COUNT(*) FROM visits WHERE visits.url = urls.id),
+				typed_count = MIN(typed_count, (SELECT COUNT(*) FROM visits WHERE visits.url = urls.id)),
+				last_visit_time = COALESCE((SELECT MAX(visit_time) FROM visits WHERE visits.url = urls.id), 0)
+				WHERE id IN (SELECT url FROM doomed_visits)`); err != nil {
+				return fmt.Errorf("failed to update urls: %w", err)
+			}
+
+			if err := tx.Commit(); err != nil {
+				return fmt.Errorf("failed to delete history: %w", err)
+			}
+
+			// cached copies of the history still hold the deleted rows
+			if err := removeSnapshots(historyPath); err != nil {
+				return err
+			}
+
+			if err := removeSearchIndex(historyPath); err != nil {
+				return err
+			}
+
+			cmd.Printf("Deleted %d visits and %d urls from %s, backup saved to %s\n", visitCount, urlCount, profile.Dir, backup)
+			return nil
+		},
+	}
+
+	flags.deleteFilters.register(cmd)
+	cmd.Flags().BoolVar(&flags.yes, "yes", false, "delete the matching rows instead of listing them")
+	return cmd
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSelectDoomedDateTerms(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "History"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(historySchema); err != nil {
+		t.Fatal(err)
+	}
+
+	january := toChromeTime(time.Date(2024, 1, 15, 12, 0, 0, 0, time.Local))
+	june := toChromeTime(time.Date(2024, 6, 15, 12, 0, 0, 0, time.Local))
+
+	// url 1 is visited in january and june, url 2 only in june
+	for _, query := range []string{
+		"INSERT INTO urls (id, url, title, visit_count, last_visit_time) VALUES (1, 'https://example.com/a', 'A', 2, ?)",
+		"INSERT INTO urls (id, url, title, visit_count, last_visit_time) VALUES (2, 'https://example.com/b', 'B', 1, ?)",
+	} {
+		if _, err := db.Exec(query, june); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, visit := range [][2]int64{{1, january}, {1, june}, {2, june}} {
+		if _, err := db.Exec("INSERT INTO visits (url, visit_time) VALUES (?, ?)", visit[0], visit[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		Query  string
+		Visits []int
+		URLs   []int
+	}{
+		{"site:example.com after:2024-03-01", []int{2, 3}, []int{2}},
+		{"site:example.com before:2024-03-01", []int{1}, nil},
+		{"site:example.com on:2024-06-15", []int{2, 3}, []int{2}},
+		{"site:example.com -before:2024-03-01", []int{3}, []int{2}},
+		{"site:example.com", []int{1, 2, 3}, []int{1, 2}},
+	} {
+		t.Run(tc.Query, func(t *testing.T) {
+			tx, err := db.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+
+			if err := (deleteFilters{Query: tc.Query}).selectDoomed(tx); err != nil {
+				t.Fatal(err)
+			}
+
+			if visits := queryIDs(t, tx, "SELECT id FROM doomed_visits ORDER BY id"); !slices.Equal(visits, tc.Visits) {
+				t.Errorf("doomed visits = %v, want %v", visits, tc.Visits)
+			}
+
+			if urls := queryIDs(t, tx, "SELECT id FROM doomed_urls ORDER BY id"); !slices.Equal(urls, tc.URLs) {
+				t.Errorf("doomed urls = %v, want %v", urls, tc.URLs)
+			}
+		})
+	}
+}
+
+func queryIDs(t *testing.T, tx *sql.Tx, query string) []int {
+	t.Helper()
+
+	rows, err := tx.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return ids
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/cli/go-gh/v2/pkg/tableprinter"
+	sb "github.com/huandu/go-sqlbuilder"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// Download states as stored in the History database, where 3 is a legacy
+// value for interrupted downloads.
+var downloadStates = []string{
+	"in_progress",
+	"complete",
+	"cancelled",
+	"interrupted",
+	"interrupted",
+}
+
+var downloadDangerTypes = []string{
+	"not_dangerous",
+	"dangerous_file",
+	"dangerous_url",
+	"dangerous_content",
+	"maybe_dangerous_content",
+	"uncommon_content",
+	"user_validated",
+	"dangerous_host",
+	"potentially_unwanted",
+	"allowlisted_by_policy",
+	"async_scanning",
+	"blocked_password_protected",
+	"blocked_too_large",
+	"sensitive_content_warning",
+	"sensitive_content_block",
+	"deep_scanned_safe",
+	"deep_scanned_opened_dangerous",
+	"prompt_for_scanning",
+	"blocked_unsupported_filetype",
+	"dangerous_account_compromise",
+}
+
+func enumName(names []string, value int) string {
+	if value >= 0 && value < len(names) {
+		return names[value]
+	}
+
+	return fmt.Sprintf("unknown(%d)", value)
+}
+
+type Download struct {
+	ID         int64     `json:"id"`
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	MimeType   string    `json:"mimeType"`
+	State      string    `json:"state"`
+	DangerType string    `json:"dangerType"`
+	StartedAt  time.Time `json:"startedAt"`
+	EndedAt    time.Time `json:"endedAt"`
+	URL        string    `json:"url"`
+	Referrer   string    `json:"referrer"`
+	TabURL     string    `json:"tabUrl"`
+	URLChain   []string  `json:"urlChain"`
+	Exists     bool      `json:"exists"`
+	Profile    string    `json:"profile"`
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+type downloadFilters struct {
+	timeRange
+	Exists bool
+	Limit  int
+}
+
+func (f *downloadFilters) register(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&f.Exists, "exists", false, "only show files still on disk")
+	cmd.Flags().IntVarP(&f.Limit, "limit", "l", 100, "limit")
+	f.timeRange.register(cmd)
+}
+
+func queryDownloads(db *sql.DB, filters downloadFilters, search string) ([]Download, error) {
+	query := sb.NewSelectBuilder()
+	query.Select("id", "target_path", "total_bytes", "mime_type", "state", "danger_type", "start_time", "end_time", "referrer", "tab_url")
+	query.From("downloads")
+	query.OrderBy("start_time DESC")
+
+	// existence can only be checked once the rows are read
+	if filters.Limit > 0 && !filters.Exists {
+		query.Limit(filters.Limit)
+	}
+
+	if search != "" {
+		query.Where(query.Or(
+			likeCondition(query, "target_path", "%", search, "%"),
+			likeCondition(query, "referrer", "%", search, "%"),
+			likeCondition(query, "tab_url", "%", search, "%"),
+			fmt.Sprintf("EXISTS (SELECT 1 FROM downloads_url_chains c WHERE c.id = downloads.id AND %s)", likeCondition(query, "c.url", "%", search, "%")),
+		))
+	}
+
+	if err := filters.timeRange.where(query, "start_time"); err != nil {
+		return nil, err
+	}
+
+	sql, args := query.Build()
+	rows, err := db.Query(sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	defer rows.Close()
+
+	var downloads []Download
+	for rows.Next() {
+		var download Download
+		var state, dangerType int
+		var startTime, endTime int64
+		if err := rows.Scan(&download.ID, &download.Path, &download.Size, &download.MimeType, &state, &dangerType, &startTime, &endTime, &download.Referrer, &download.TabURL); err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		download.State = enumName(downloadStates, state)
+		download.DangerType = enumName(downloadDangerTypes, dangerType)
+		download.StartedAt = chromeTime(startTime)
+		download.EndedAt = chromeTime(endTime)
+		if _, err := os.Stat(download.Path); err == nil {
+			download.Exists = true
+		}
+
+		if filters.Exists && !download.Exists {
+			continue
+		}
+
+		downloads = append(downloads, download)
+		if filters.Limit > 0 && len(downloads) == filters.Limit {
+			break
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+
+	chains, err := db.Query("SELECT id, url FROM downloads_url_chains ORDER BY id, chain_index")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	defer chains.Close()
+
+	urlChains := make(map[int64][]string)
+	for chains.Next() {
+		var id int64
+		var url string
+		if err := chains.Scan(&id, &url); err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		urlChains[id] = append(urlChains[id], url)
+	}
+
+	for i, download := range downloads {
+		downloads[i].URLChain = urlChains[download.ID]
+		if len(downloads[i].URLChain) > 0 {
+			downloads[i].URL = downloads[i].URLChain[0]
+		}
+	}
+
+	return downloads, nil
+}
+
+// queryProfileDownloads gathers downloads from every selected profile.
+func queryProfileDownloads(filters downloadFilters, search string) ([]Download, error) {
+	var downloads []Download
+	if err := eachHistory(false, func(profile Profile, db *sql.DB) error {
+		profileDownloads, err := queryDownloads(db, filters, search)
+		if err != nil {
+			return err
+		}
+
+		for i := range profileDownloads {
+			profileDownloads[i].Profile = profile.Dir
+		}
+		downloads = append(downloads, profileDownloads...)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(downloads, func(i, j int) bool {
+		return downloads[i].StartedAt.After(downloads[j].StartedAt)
+	})
+
+	if filters.Limit > 0 && len(downloads) > filters.Limit {
+		downloads = downloads[:filters.Limit]
+	}
+
+	return downloads, nil
+}
+
+func printDownloads(downloads []Download, asJson bool) error {
+	if asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(downloads)
+	}
+
+	var printer tableprinter.TablePrinter
+	if !isatty.IsTerminal(os.Stdout.Fd()) {
+		printer = tableprinter.New(os.Stdout, false, 0)
+	} else {
+		w, _, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			return err
+		}
+
+		printer = tableprinter.New(os.Stdout, true, w)
+	}
+
+	header := []string{"ID", "StartedAt", "State", "Size", "Path", "URL"}
+	if profileFlag == "all" {
+		header = append(header, "Profile")
+	}
+
+	printer.AddHeader(header)
+	for _, download := range downloads {
+		printer.AddField(fmt.Sprintf("%d", download.ID))
+		printer.AddField(download.StartedAt.Local().Format(time.DateTime))
+		printer.AddField(download.State)
+		printer.AddField(formatBytes(download.Size))
+		printer.AddField(download.Path)
+		printer.AddField(download.URL)
+		if profileFlag == "all" {
+			printer.AddField(download.Profile)
+		}
+		printer.EndRow()
+	}
+
+	return printer.Render()
+}
+
+func NewCmdDownloads() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "downloads",
+		Short: "Browse downloads history",
+	}
+
+	cmd.AddCommand(NewCmdDownloadsList())
+	cmd.AddCommand(NewCmdDownloadsSearch())
+
+	return cmd
+}
+
+func NewCmdDownloadsList() *cobra.Command {
+	var flags struct {
+		downloadFilters
+		Json bool
+	}
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List downloads",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			downloads, err := queryProfileDownloads(flags.downloadFilters, "")
+			if err != nil {
+				return err
+			}
+
+			return printDownloads(downloads, flags.Json)
+		},
+	}
+
+	flags.register(cmd)
+	cmd.Flags().BoolVar(&flags.Json, "json", false, "output as json")
+	return cmd
+}
+
+func NewCmdDownloadsSearch() *cobra.Command {
+	var flags struct {
+		downloadFilters
+		Json bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "search <query>",
+		Short: "Search downloads by path, url or referrer",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			downloads, err := queryProfileDownloads(flags.downloadFilters, args[0])
+			if err != nil {
+				return err
+			}
+
+			return printDownloads(downloads, flags.Json)
+		},
+	}
+
+	flags.register(cmd)
+	cmd.Flags().BoolVar(&flags.Json, "json", false, "output as json")
+	return cmd
+}
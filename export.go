@@ -0,0 +1,635 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pomdtr/arc/sidebar"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdExport() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export data from Arc",
+	}
+
+	cmd.AddCommand(NewCmdExportBookmarks())
+
+	return cmd
+}
+
+func NewCmdExportBookmarks() *cobra.Command {
+	var flags struct {
+		Format   string
+		Space    string
+		IconsDir string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "bookmarks",
+		Short: "Export favorites, pinned tabs and folders as bookmarks",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := loadSidebar()
+			if err != nil {
+				return err
+			}
+
+			roots := bookmarkRoots(s.Tree(), flags.Space)
+			if flags.Space != "" && len(roots) == 0 {
+				return fmt.Errorf("space not found: %s", flags.Space)
+			}
+
+			if flags.IconsDir != "" {
+				if err := resolveBookmarkIcons(roots, flags.IconsDir); err != nil {
+					return err
+				}
+			}
+
+			switch flags.Format {
+			case "html":
+				return writeBookmarksHTML(os.Stdout, roots)
+			case "md":
+				return writeBookmarksMarkdown(os.Stdout, roots)
+			case "opml":
+				return writeBookmarksOPML(os.Stdout, roots)
+			case "json":
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				encoder.SetEscapeHTML(false)
+				return encoder.Encode(roots)
+			default:
+				return fmt.Errorf("unknown format: %s", flags.Format)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Format, "format", "f", "html", "output format (html, md, opml, json)")
+	cmd.Flags().StringVar(&flags.Space, "space", "", "only export this space")
+	cmd.Flags().StringVar(&flags.IconsDir, "icons-dir", "", "write favicons to this dir and include their paths in the html and json exports")
+	return cmd
+}
+
+// eachBookmark calls fn with each link of a bookmark tree, depth first.
+func eachBookmark(nodes []sidebar.Node, fn func(node *sidebar.Node)) {
+	for i := range nodes {
+		if nodes[i].Type == "folder" {
+			eachBookmark(nodes[i].Children, fn)
+			continue
+		}
+
+		fn(&nodes[i])
+	}
+}
+
+// resolveBookmarkIcons sets the icon of each link of a bookmark tree from the
+// favicons of the selected profiles.
+func resolveBookmarkIcons(roots []sidebar.Node, iconsDir string) error {
+	var urls []string
+	eachBookmark(roots, func(node *sidebar.Node) {
+		urls = append(urls, node.URL)
+	})
+
+	icons, err := resolveIcons(urls, iconsDir)
+	if err != nil {
+		return err
+	}
+
+	eachBookmark(roots, func(node *sidebar.Node) {
+		node.Icon = icons[0]
+		icons = icons[1:]
+	})
+
+	return nil
+}
+
+// bookmarkRoots turns the favorites and the pinned items of each space into
+// top-level folders.
+func bookmarkRoots(tree sidebar.Tree, space string) []sidebar.Node {
+	var roots []sidebar.Node
+	if space == "" && len(tree.Favorites) > 0 {
+		roots = append(roots, sidebar.Node{
+			Type:     "folder",
+			Title:    "Favorites",
+			Children: tree.Favorites,
+		})
+	}
+
+	for _, spaceTree := range tree.Spaces {
+		if space != "" && !strings.EqualFold(spaceTree.Title, space) && spaceTree.ID != space {
+			continue
+		}
+
+		roots = append(roots, sidebar.Node{
+			ID:       spaceTree.ID,
+			Type:     "folder",
+			Title:    spaceTree.Title,
+			Children: spaceTree.Pinned,
+		})
+	}
+
+	return roots
+}
+
+func writeBookmarksHTML(w io.Writer, roots []sidebar.Node) error {
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+`)
+
+	var write func(nodes []sidebar.Node, depth int)
+	write = func(nodes []sidebar.Node, depth int) {
+		indent := strings.Repeat("    ", depth)
+		for _, node := range nodes {
+			if node.Type == "folder" {
+				fmt.Fprintf(&b, "%s<DT><H3>%s</H3>\n", indent, html.EscapeString(node.Title))
+				fmt.Fprintf(&b, "%s<DL><p>\n", indent)
+				write(node.Children, depth+1)
+				fmt.Fprintf(&b, "%s</DL><p>\n", indent)
+				continue
+			}
+
+			var icon string
+			if node.Icon != "" {
+				icon = fmt.Sprintf(` ICON_URI="%s"`, html.EscapeString((&url.URL{Scheme: "file", Path: node.Icon}).String()))
+			}
+
+			fmt.Fprintf(&b, "%s<DT><A HREF=\"%s\"%s>%s</A>\n", indent, html.EscapeString(node.URL), icon, html.EscapeString(node.Title))
+		}
+	}
+
+	write(roots, 1)
+	b.WriteString("</DL><p>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeBookmarksMarkdown(w io.Writer, roots []sidebar.Node) error {
+	// titles are escaped so that markup in them is shown as text
+	escape := strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`, `*`, `\*`, `<`, `\<`, `>`, `\>`, `&`, `\&`)
+
+	var b strings.Builder
+	var write func(nodes []sidebar.Node, depth int)
+	write = func(nodes []sidebar.Node, depth int) {
+		indent := strings.Repeat("  ", depth)
+		for _, node := range nodes {
+			if node.Type == "folder" {
+				fmt.Fprintf(&b, "%s- **%s**\n", indent, escape.Replace(node.Title))
+				write(node.Children, depth+1)
+				continue
+			}
+
+			fmt.Fprintf(&b, "%s- [%s](<%s>)\n", indent, escape.Replace(node.Title), node.URL)
+		}
+	}
+
+	for i, root := range roots {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		fmt.Fprintf(&b, "## %s\n\n", escape.Replace(root.Title))
+		write(root.Children, 0)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Type     string        `xml:"type,attr,omitempty"`
+	URL      string        `xml:"url,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+func writeBookmarksOPML(w io.Writer, roots []sidebar.Node) error {
+	var convert func(nodes []sidebar.Node) []opmlOutline
+	convert = func(nodes []sidebar.Node) []opmlOutline {
+		var outlines []opmlOutline
+		for _, node := range nodes {
+			if node.Type == "folder" {
+				outlines = append(outlines, opmlOutline{
+					Text:     node.Title,
+					Outlines: convert(node.Children),
+				})
+				continue
+			}
+
+			outlines = append(outlines, opmlOutline{
+				Text: node.Title,
+				Type: "link",
+				URL:  node.URL,
+			})
+		}
+
+		return outlines
+	}
+
+	document := struct {
+		XMLName xml.Name `xml:"opml"`
+		Version string   `xml:"version,attr"`
+		Head    struct {
+			Title string `xml:"title"`
+		} `xml:"head"`
+		Body struct {
+			Outlines []opmlOutline `xml:"outline"`
+		} `xml:"body"`
+	}{Version: "2.0"}
+	document.Head.Title = "Arc Bookmarks"
+	document.Body.Outlines = convert(roots)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// historyWriter writes history entries one at a time to an export format.
+// Abort is called instead of Close when the export fails.
+type historyWriter interface {
+	Write(entry HistoryEntry) error
+	Close() error
+	Abort()
+}
+
+type csvHistoryWriter struct {
+	writer *csv.Writer
+}
+
+func newCSVHistoryWriter(w io.Writer) (*csvHistoryWriter, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"id", "url", "title", "domain", "visitCount", "typedCount", "hidden", "firstVisitedAt", "lastVisitedAt", "dwellSeconds", "score", "profile", "icon"}); err != nil {
+		return nil, err
+	}
+
+	return &csvHistoryWriter{writer: writer}, nil
+}
+
+func (w *csvHistoryWriter) Write(entry HistoryEntry) error {
+	return w.writer.Write([]string{
+		strconv.Itoa(entry.ID),
+		entry.URL,
+		entry.Title,
+		entry.Domain,
+		strconv.Itoa(entry.VisitCount),
+		strconv.Itoa(entry.TypedCount),
+		strconv.FormatBool(entry.Hidden),
+		formatTime(entry.FirstVisitedAt),
+		formatTime(entry.LastVisitedAt),
+		strconv.FormatFloat(entry.DwellSeconds, 'f', -1, 64),
+		strconv.FormatFloat(entry.Score, 'f', -1, 64),
+		entry.Profile,
+		entry.Icon,
+	})
+}
+
+func (w *csvHistoryWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvHistoryWriter) Abort() {}
+
+type jsonlHistoryWriter struct {
+	encoder *json.Encoder
+}
+
+func newJSONLHistoryWriter(w io.Writer) *jsonlHistoryWriter {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return &jsonlHistoryWriter{encoder: encoder}
+}
+
+func (w *jsonlHistoryWriter) Write(entry HistoryEntry) error {
+	return w.encoder.Encode(entry)
+}
+
+func (w *jsonlHistoryWriter) Close() error {
+	return nil
+}
+
+func (w *jsonlHistoryWriter) Abort() {}
+
+// htmlHistoryWriter writes history as a Netscape bookmark file, which most
+// browsers can import.
+type htmlHistoryWriter struct {
+	writer io.Writer
+}
+
+func newHTMLHistoryWriter(w io.Writer) (*htmlHistoryWriter, error) {
+	if _, err := io.WriteString(w, `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>History</TITLE>
+<H1>History</H1>
+<DL><p>
+`); err != nil {
+		return nil, err
+	}
+
+	return &htmlHistoryWriter{writer: w}, nil
+}
+
+func (w *htmlHistoryWriter) Write(entry HistoryEntry) error {
+	title := entry.Title
+	if title == "" {
+		title = entry.URL
+	}
+
+	_, err := fmt.Fprintf(w.writer, "    <DT><A HREF=\"%s\" ADD_DATE=\"%d\" LAST_VISIT=\"%d\">%s</A>\n", html.EscapeString(entry.URL), unixSeconds(entry.FirstVisitedAt), unixSeconds(entry.LastVisitedAt), html.EscapeString(title))
+	return err
+}
+
+func (w *htmlHistoryWriter) Close() error {
+	_, err := io.WriteString(w.writer, "</DL><p>\n")
+	return err
+}
+
+func (w *htmlHistoryWriter) Abort() {}
+
+func unixSeconds(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.Unix()
+}
+
+// historyExportSchema is the schema of SQLite exports. Timestamps are RFC
+// 3339 strings, empty when unknown, and ids are the ids of the urls in the
+// History database of their profile.
+const historyExportSchema = `CREATE TABLE history (
+	id INTEGER NOT NULL,
+	url TEXT NOT NULL,
+	title TEXT NOT NULL,
+	domain TEXT NOT NULL,
+	visit_count INTEGER NOT NULL,
+	typed_count INTEGER NOT NULL,
+	hidden INTEGER NOT NULL,
+	first_visited_at TEXT NOT NULL,
+	last_visited_at TEXT NOT NULL,
+	dwell_seconds REAL NOT NULL,
+	score REAL NOT NULL,
+	profile TEXT NOT NULL,
+	icon TEXT NOT NULL,
+	PRIMARY KEY (profile, id)
+);`
+
+type sqliteHistoryWriter struct {
+	path   string
+	db     *sql.DB
+	tx     *sql.Tx
+	insert *sql.Stmt
+}
+
+// removeSQLiteExport removes an export that failed along with its journal,
+// so that it can be run again.
+func removeSQLiteExport(path string) {
+	os.Remove(path)
+	os.Remove(path + "-journal")
+}
+
+func newSQLiteHistoryWriter(path string) (*sqliteHistoryWriter, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("%s already exists", path)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open db: %w", err)
+	}
+
+	if _, err := db.Exec(historyExportSchema); err != nil {
+		db.Close()
+		removeSQLiteExport(path)
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		db.Close()
+		removeSQLiteExport(path)
+		return nil, fmt.Errorf("failed to open db: %w", err)
+	}
+
+	insert, err := tx.Prepare(`INSERT INTO history (id, url, title, domain, visit_count, typed_count, hidden, first_visited_at, last_visited_at, dwell_seconds, score, profile, icon)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		db.Close()
+		removeSQLiteExport(path)
+		return nil, fmt.Errorf("failed to open db: %w", err)
+	}
+
+	return &sqliteHistoryWriter{path: path, db: db, tx: tx, insert: insert}, nil
+}
+
+func (w *sqliteHistoryWriter) Write(entry HistoryEntry) error {
+	if _, err := w.insert.Exec(entry.ID, entry.URL, entry.Title, entry.Domain, entry.VisitCount, entry.TypedCount, entry.Hidden, formatTime(entry.FirstVisitedAt), formatTime(entry.LastVisitedAt), entry.DwellSeconds, entry.Score, entry.Profile, entry.Icon); err != nil {
+		return fmt.Errorf("failed to insert: %w", err)
+	}
+
+	return nil
+}
+
+func (w *sqliteHistoryWriter) Close() error {
+	w.insert.Close()
+	if err := w.tx.Commit(); err != nil {
+		w.db.Close()
+		removeSQLiteExport(w.path)
+		return fmt.Errorf("failed to save export: %w", err)
+	}
+
+	return w.db.Close()
+}
+
+// Abort rolls back the rows written so far and removes the file.
+func (w *sqliteHistoryWriter) Abort() {
+	w.insert.Close()
+	w.tx.Rollback()
+	w.db.Close()
+	removeSQLiteExport(w.path)
+}
+
+func NewCmdHistoryExport() *cobra.Command {
+	var flags struct {
+		historySearch
+		format   string
+		out      string
+		tz       string
+		archive  bool
+		iconsDir string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export history to a file",
+		Long: `Export history to a file.
+
+Rows are streamed to the output as they are read, except when they are
+ranked with --fuzzy or --sort frecency. When reading all profiles, rows are
+grouped by profile.
+
+The sqlite format writes a single table:
+
+` + historyExportSchema + `
+
+` + historyQueryHelp,
+		Example: `  arc history export --format csv --out history.csv
+  arc history export --format sqlite --out github.sqlite -q site:github.com`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			location, err := loadLocation(flags.tz)
+			if err != nil {
+				return err
+			}
+
+			if _, _, err := flags.historySearch.build(); err != nil {
+				return err
+			}
+
+			toFile := flags.out != "" && flags.out != "-"
+			switch flags.format {
+			case "csv", "jsonl", "html":
+			case "sqlite":
+				if !toFile {
+					return errors.New("the sqlite format needs an output file, set it with --out")
+				}
+			default:
+				return fmt.Errorf("unknown format: %s", flags.format)
+			}
+
+			var output io.Writer = os.Stdout
+			var f *os.File
+			if toFile && flags.format != "sqlite" {
+				if f, err = os.Create(flags.out); err != nil {
+					return fmt.Errorf("failed to create output file: %w", err)
+				}
+				output = f
+			}
+
+			// a failed export leaves no partial file behind
+			removeOutput := func() {
+				if f != nil {
+					f.Close()
+					os.Remove(flags.out)
+				}
+			}
+
+			buffered := bufio.NewWriter(output)
+			var writer historyWriter
+			switch flags.format {
+			case "csv":
+				writer, err = newCSVHistoryWriter(buffered)
+			case "jsonl":
+				writer = newJSONLHistoryWriter(buffered)
+			case "html":
+				writer, err = newHTMLHistoryWriter(buffered)
+			case "sqlite":
+				writer, err = newSQLiteHistoryWriter(flags.out)
+			}
+
+			if err != nil {
+				removeOutput()
+				return err
+			}
+
+			var icons *iconCache
+			if flags.iconsDir != "" {
+				if icons, err = newIconCache(flags.iconsDir); err != nil {
+					writer.Abort()
+					removeOutput()
+					return err
+				}
+				defer icons.Close()
+			}
+
+			if err := eachHistory(flags.archive, func(profile Profile, db *sql.DB) error {
+				faviconsPath, err := profile.Path("Favicons")
+				if err != nil {
+					return err
+				}
+
+				shortcutsPath, err := profile.Path("Shortcuts")
+				if err != nil {
+					return err
+				}
+
+				search, err := flags.historySearch.withShortcuts(shortcutsPath)
+				if err != nil {
+					return err
+				}
+
+				return search.each(db, location, func(entry HistoryEntry) error {
+					entry.Profile = profile.Dir
+					if icons != nil {
+						if entry.Icon, err = icons.Path(faviconsPath, entry.URL); err != nil {
+							return err
+						}
+					}
+					return writer.Write(entry)
+				})
+			}); err != nil {
+				writer.Abort()
+				removeOutput()
+				return err
+			}
+
+			if err := writer.Close(); err != nil {
+				removeOutput()
+				return err
+			}
+
+			if err := buffered.Flush(); err != nil {
+				removeOutput()
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+
+			if f != nil {
+				if err := f.Close(); err != nil {
+					os.Remove(flags.out)
+					return fmt.Errorf("failed to write output: %w", err)
+				}
+			}
+
+			return nil
+		},
+	}
+
+	flags.historySearch.register(cmd, 0)
+	cmd.Flags().StringVarP(&flags.format, "format", "f", "jsonl", "output format (csv, jsonl, html, sqlite)")
+	cmd.Flags().StringVarP(&flags.out, "out", "o", "", "output file, defaults to stdout")
+	cmd.Flags().StringVar(&flags.tz, "tz", "Local", "timezone used for timestamps")
+	cmd.Flags().BoolVar(&flags.archive, "archive", false, "export the archive kept by arc history sync")
+	cmd.Flags().StringVar(&flags.iconsDir, "icons-dir", "", "write favicons to this dir and include their paths in the export")
+
+	return cmd
+}
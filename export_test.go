@@ -0,0 +1,171 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pomdtr/arc/sidebar"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// bookmarkFixture has a nested folder and titles needing escaping.
+var bookmarkFixture = []sidebar.Node{
+	{
+		Type:  "folder",
+		Title: "Favorites",
+		Children: []sidebar.Node{
+			{Type: "tab", Title: "GitHub", URL: "https://github.com/"},
+		},
+	},
+	{
+		ID:    "space-1",
+		Type:  "folder",
+		Title: "Work & Play",
+		Children: []sidebar.Node{
+			{Type: "tab", Title: `Use <b> & "quotes"`, URL: "https://example.com/?a=1&b=2"},
+			{
+				Type:  "folder",
+				Title: "Docs <draft>",
+				Children: []sidebar.Node{
+					{Type: "tab", Title: "[Go] Reference", URL: "https://go.dev/ref/spec"},
+				},
+			},
+		},
+	},
+}
+
+func TestWriteBookmarks(t *testing.T) {
+	for _, tc := range []struct {
+		Golden string
+		Write  func(w io.Writer, roots []sidebar.Node) error
+	}{
+		{"bookmarks.html", writeBookmarksHTML},
+		{"bookmarks.md", writeBookmarksMarkdown},
+		{"bookmarks.opml", writeBookmarksOPML},
+	} {
+		t.Run(tc.Golden, func(t *testing.T) {
+			var b strings.Builder
+			if err := tc.Write(&b, bookmarkFixture); err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", tc.Golden)
+			if *update {
+				if err := os.WriteFile(golden, []byte(b.String()), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if b.String() != string(want) {
+				t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+			}
+		})
+	}
+}
+
+func TestSQLiteHistoryWriterAbort(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.sqlite")
+	entry := HistoryEntry{ID: 1, URL: "https://example.com/", Title: "Example", Profile: "Default"}
+
+	writer, err := newSQLiteHistoryWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Write(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	// rows are keyed by profile and id
+	if err := writer.Write(entry); err == nil {
+		t.Fatal("writing a duplicate row succeeded, want an error")
+	}
+	writer.Abort()
+
+	if files, _ := filepath.Glob(path + "*"); len(files) > 0 {
+		t.Fatalf("aborted export left %v behind", files)
+	}
+
+	// the export can be run again
+	writer, err = newSQLiteHistoryWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Write(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM history").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 1 {
+		t.Errorf("got %d rows, want 1", count)
+	}
+
+	if _, err := newSQLiteHistoryWriter(path); err == nil {
+		t.Error("overwriting an export succeeded, want an error")
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("existing export was removed: %v", err)
+	}
+}
+
+func TestBookmarkIcons(t *testing.T) {
+	roots := []sidebar.Node{{
+		Type:  "folder",
+		Title: "Favorites",
+		Children: []sidebar.Node{
+			{Type: "tab", Title: "GitHub", URL: "https://github.com/", Icon: "/tmp/arc icons/1f2e.png"},
+			{Type: "tab", Title: "Example", URL: "https://example.com/"},
+		},
+	}}
+
+	var b strings.Builder
+	if err := writeBookmarksHTML(&b, roots); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		`<DT><A HREF="https://github.com/" ICON_URI="file:///tmp/arc%20icons/1f2e.png">GitHub</A>`,
+		`<DT><A HREF="https://example.com/">Example</A>`,
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("html export is missing %s:\n%s", line, b.String())
+		}
+	}
+
+	data, err := json.Marshal(roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(data), `"icon":"/tmp/arc icons/1f2e.png"`) || strings.Count(string(data), `"icon"`) != 1 {
+		t.Errorf("json export has wrong icons: %s", data)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+)
+
+var errNoFavicon = errors.New("no favicon found")
+
+// faviconQuery selects the largest bitmap of the icons of the pages matching
+// a condition on icon_mapping, which must be able to use the index on
+// page_url.
+const faviconQuery = `SELECT b.image_data FROM icon_mapping m
+	JOIN favicon_bitmaps b ON b.icon_id = m.icon_id
+	WHERE %s AND length(b.image_data) > 0
+	ORDER BY b.width DESC, b.last_updated DESC
+	LIMIT 1`
+
+// readFavicon returns the PNG bytes of the icon of a page from a Favicons
+// database. Pages never visited fall back to the icon of another page of the
+// same origin, and the largest bitmap is preferred.
+func readFavicon(db *sql.DB, pageURL string) ([]byte, error) {
+	data, err := queryFavicon(db, "m.page_url = ?", pageURL)
+	if !errors.Is(err, errNoFavicon) {
+		return data, err
+	}
+
+	origin := pageURL
+	if u, err := url.Parse(pageURL); err == nil && u.Host != "" {
+		origin = u.Scheme + "://" + u.Host + "/"
+	}
+
+	if origin == "" {
+		return nil, errNoFavicon
+	}
+
+	// pages starting with the origin sort between it and the origin with its
+	// last byte incremented, urls being stored ascii encoded
+	end := origin[:len(origin)-1] + string([]byte{origin[len(origin)-1] + 1})
+	return queryFavicon(db, "m.page_url >= ? AND m.page_url < ?", origin, end)
+}
+
+func queryFavicon(db *sql.DB, where string, args ...interface{}) ([]byte, error) {
+	var data []byte
+	err := db.QueryRow(fmt.Sprintf(faviconQuery, where), args...).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errNoFavicon
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query favicons: %w", err)
+	}
+
+	return data, nil
+}
+
+// iconCache writes favicons to a directory for launchers to display. Files
+// are named after a hash of their content, so pages sharing an icon share a
+// file and unchanged icons are not written again.
+type iconCache struct {
+	dir      string
+	dbs      map[string]*sql.DB
+	cleanups []func()
+}
+
+func newIconCache(dir string) (*iconCache, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve icons dir: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create icons dir: %w", err)
+	}
+
+	return &iconCache{dir: dir, dbs: make(map[string]*sql.DB)}, nil
+}
+
+// Path returns the path of the icon file of a page, or an empty string when
+// the Favicons database has none.
+func (c *iconCache) Path(faviconsPath string, pageURL string) (string, error) {
+	db, ok := c.dbs[faviconsPath]
+	if !ok {
+		var cleanup func()
+		var err error
+		db, cleanup, err = openSnapshot(faviconsPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			db = nil
+		} else if err != nil {
+			return "", err
+		} else {
+			c.cleanups = append(c.cleanups, cleanup)
+		}
+		c.dbs[faviconsPath] = db
+	}
+
+	if db == nil {
+		return "", nil
+	}
+
+	data, err := readFavicon(db, pageURL)
+	if errors.Is(err, errNoFavicon) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	path := filepath.Join(c.dir, hex.EncodeToString(sum[:8])+".png")
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write icon: %w", err)
+	}
+
+	return path, nil
+}
+
+func (c *iconCache) Close() {
+	for _, cleanup := range c.cleanups {
+		cleanup()
+	}
+}
+
+func NewCmdFavicon() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "favicon <url>",
+		Short: "Write the favicon of a page as PNG to stdout",
+		Long: `Write the favicon of a page as PNG to stdout.
+
+Icons are read from the favicons cached by Arc, so only pages of sites
+visited before have one.`,
+		Example: `  arc favicon https://github.com > github.png`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if isatty.IsTerminal(os.Stdout.Fd()) {
+				return errors.New("refusing to write an image to a terminal, redirect stdout to a file")
+			}
+
+			profile, err := selectedProfile()
+			if err != nil {
+				return err
+			}
+
+			faviconsPath, err := profile.Path("Favicons")
+			if err != nil {
+				return err
+			}
+
+			db, cleanup, err := openSnapshot(faviconsPath)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			data, err := readFavicon(db, args[0])
+			if err != nil {
+				return fmt.Errorf("%s: %w", args[0], err)
+			}
+
+			_, err = os.Stdout.Write(data)
+			return err
+		},
+	}
+
+	return cmd
+}
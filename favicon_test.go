@@ -0,0 +1,108 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// faviconsSchema is the part of the schema of a Chromium Favicons database
+// read by arc.
+const faviconsSchema = `
+CREATE TABLE icon_mapping(id INTEGER PRIMARY KEY,page_url LONGVARCHAR NOT NULL,icon_id INTEGER,page_url_type INTEGER DEFAULT 0);
+CREATE INDEX icon_mapping_page_url_idx ON icon_mapping(page_url);
+CREATE INDEX icon_mapping_icon_id_idx ON icon_mapping(icon_id);
+CREATE TABLE favicon_bitmaps(id INTEGER PRIMARY KEY,icon_id INTEGER NOT NULL,last_updated INTEGER DEFAULT 0,image_data BLOB,width INTEGER DEFAULT 0,height INTEGER DEFAULT 0,last_requested INTEGER DEFAULT 0);
+CREATE INDEX favicon_bitmaps_icon_id ON favicon_bitmaps(icon_id);
+`
+
+func openTestFavicons(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "Favicons"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, query := range []string{
+		faviconsSchema,
+		`INSERT INTO icon_mapping (page_url, icon_id) VALUES
+			('https://github.com/pomdtr/arc', 1),
+			('https://github.com/', 2),
+			('https://github.community/', 3),
+			('https://example.com/', 4)`,
+		`INSERT INTO favicon_bitmaps (icon_id, image_data, width) VALUES
+			(1, 'arc-16', 16),
+			(2, 'github-16', 16),
+			(2, 'github-32', 32),
+			(3, 'community-64', 64),
+			(4, '', 16)`,
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return db
+}
+
+func TestReadFavicon(t *testing.T) {
+	db := openTestFavicons(t)
+
+	for _, tt := range []struct {
+		pageURL string
+		want    string
+	}{
+		// the icon of the page wins over larger ones of its origin
+		{"https://github.com/pomdtr/arc", "arc-16"},
+		{"https://github.com/", "github-32"},
+		// unknown pages fall back to the largest icon of their origin only
+		{"https://github.com/pomdtr/arc/issues", "github-32"},
+		{"https://github.community/t/1", "community-64"},
+		// empty bitmaps are skipped
+		{"https://example.com/", ""},
+		{"https://gitlab.com/", ""},
+	} {
+		data, err := readFavicon(db, tt.pageURL)
+		if tt.want == "" {
+			if !errors.Is(err, errNoFavicon) {
+				t.Errorf("readFavicon(%s) = %q, %v, want no favicon", tt.pageURL, data, err)
+			}
+			continue
+		}
+
+		if err != nil || string(data) != tt.want {
+			t.Errorf("readFavicon(%s) = %q, %v, want %q", tt.pageURL, data, err, tt.want)
+		}
+	}
+}
+
+func TestFaviconQueryUsesIndex(t *testing.T) {
+	db := openTestFavicons(t)
+
+	for _, where := range []string{"m.page_url = ?", "m.page_url >= ? AND m.page_url < ?"} {
+		rows, err := db.Query("EXPLAIN QUERY PLAN "+fmt.Sprintf(faviconQuery, where), "https://github.com/", "https://github.com0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var plan []string
+		for rows.Next() {
+			var id, parent, notused int
+			var detail string
+			if err := rows.Scan(&id, &parent, &notused, &detail); err != nil {
+				t.Fatal(err)
+			}
+			plan = append(plan, detail)
+		}
+		rows.Close()
+
+		if !strings.Contains(strings.Join(plan, "\n"), "USING INDEX icon_mapping_page_url_idx") {
+			t.Errorf("%s: plan does not use the page url index:\n%s", where, strings.Join(plan, "\n"))
+		}
+	}
+}
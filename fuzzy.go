@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	fuzzyScoreMatch       = 16
+	fuzzyBonusConsecutive = 8
+	fuzzyBonusBoundary    = 10
+	fuzzyPenaltyGap       = 1
+
+	// fuzzyBonusShortcut ranks urls reached before by typing the pattern in
+	// the address bar above other matches
+	fuzzyBonusShortcut = 1000
+)
+
+// fuzzyScore scores text against a space separated pattern, in the spirit of
+// fzf: every word of the pattern must appear in order, and matches on word
+// boundaries or on consecutive characters score higher.
+func fuzzyScore(pattern string, text string) (int, bool) {
+	total := 0
+	for _, word := range strings.Fields(pattern) {
+		score, ok := fuzzyScoreWord([]rune(strings.ToLower(word)), []rune(strings.ToLower(text)))
+		if !ok {
+			return 0, false
+		}
+		total += score
+	}
+
+	return total, true
+}
+
+func fuzzyScoreWord(pattern []rune, text []rune) (int, bool) {
+	if len(pattern) == 0 {
+		return 0, true
+	}
+
+	// find the first window containing the pattern, then shrink it from the
+	// left by matching backwards from its end
+	pi, end := 0, -1
+	for ti := 0; ti < len(text); ti++ {
+		if text[ti] == pattern[pi] {
+			pi++
+			if pi == len(pattern) {
+				end = ti
+				break
+			}
+		}
+	}
+
+	if end < 0 {
+		return 0, false
+	}
+
+	pi, start := len(pattern)-1, end
+	for ti := end; ti >= 0; ti-- {
+		if text[ti] == pattern[pi] {
+			pi--
+			if pi < 0 {
+				start = ti
+				break
+			}
+		}
+	}
+
+	score, pi, previous := 0, 0, -2
+	for ti := start; ti <= end && pi < len(pattern); ti++ {
+		if text[ti] != pattern[pi] {
+			score -= fuzzyPenaltyGap
+			continue
+		}
+
+		score += fuzzyScoreMatch
+		if ti == previous+1 {
+			score += fuzzyBonusConsecutive
+		}
+		if ti == 0 || isWordBoundary(text[ti-1]) {
+			score += fuzzyBonusBoundary
+		}
+
+		previous = ti
+		pi++
+	}
+
+	return score, true
+}
+
+func isWordBoundary(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
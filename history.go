@@ -3,10 +3,16 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"io/fs"
+	"math"
+	"net/url"
 	"os"
-	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cli/go-gh/v2/pkg/tableprinter"
 	sb "github.com/huandu/go-sqlbuilder"
@@ -16,114 +22,652 @@ import (
 	_ "modernc.org/sqlite"
 )
 
-var historyPath = filepath.Join(os.Getenv("HOME"), "Library", "Application Support", "Arc", "User Data", "Default", "History")
+// Chromium stores timestamps as microseconds since 1601-01-01 UTC.
+const chromeEpochOffset = 11644473600000000
+
+func chromeTime(microseconds int64) time.Time {
+	if microseconds == 0 {
+		return time.Time{}
+	}
+
+	return time.UnixMicro(microseconds - chromeEpochOffset)
+}
+
+func toChromeTime(t time.Time) int64 {
+	return t.UnixMicro() + chromeEpochOffset
+}
+
+// openHistory opens a copy of the History database of the selected profile,
+// as Arc keeps the original locked while running.
+func openHistory() (*sql.DB, func(), error) {
+	profile, err := selectedProfile()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	path, err := profile.Path("History")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return openSnapshot(path)
+}
+
+// eachHistory runs fn against the History database, or the synced archive,
+// of every selected profile, skipping profiles without one when reading all
+// of them.
+func eachHistory(archive bool, fn func(profile Profile, db *sql.DB) error) error {
+	profiles, err := selectedProfiles()
+	if err != nil {
+		return err
+	}
+
+	for _, profile := range profiles {
+		var db *sql.DB
+		var cleanup func()
+		if archive {
+			db, cleanup, err = openHistoryArchive(profile)
+		} else {
+			var path string
+			if path, err = profile.Path("History"); err != nil {
+				return err
+			}
+			db, cleanup, err = openSnapshot(path)
+		}
+
+		if errors.Is(err, fs.ErrNotExist) && len(profiles) > 1 {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = fn(profile, db)
+		cleanup()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
 type HistoryEntry struct {
-	ID            int    `db:"id" json:"id"`
-	URL           string `db:"url" json:"url"`
-	Title         string `db:"title" json:"title"`
-	LastVisitedAt string `db:"lastVisitedAt" json:"lastVisitedAt"`
+	ID             int       `db:"id" json:"id"`
+	URL            string    `db:"url" json:"url"`
+	Title          string    `db:"title" json:"title"`
+	Domain         string    `db:"domain" json:"domain"`
+	VisitCount     int       `db:"visitCount" json:"visitCount"`
+	TypedCount     int       `db:"typedCount" json:"typedCount"`
+	Hidden         bool      `db:"hidden" json:"hidden"`
+	FirstVisitedAt time.Time `db:"firstVisitedAt" json:"firstVisitedAt"`
+	LastVisitedAt  time.Time `db:"lastVisitedAt" json:"lastVisitedAt"`
+	DwellSeconds   float64   `db:"dwellSeconds" json:"dwellSeconds"`
+	Score          float64   `json:"score,omitempty"`
+	Profile        string    `db:"profile" json:"profile"`
+	Source         string    `db:"source" json:"source"`
+	Icon           string    `db:"icon" json:"icon,omitempty"`
+}
+
+func urlDomain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return u.Hostname()
+}
+
+type historyColumn struct {
+	Name   string
+	Header string
+	Value  func(entry HistoryEntry) string
+}
+
+var historyColumns = []historyColumn{
+	{"id", "ID", func(e HistoryEntry) string { return strconv.Itoa(e.ID) }},
+	{"url", "URL", func(e HistoryEntry) string { return e.URL }},
+	{"title", "Title", func(e HistoryEntry) string { return e.Title }},
+	{"domain", "Domain", func(e HistoryEntry) string { return e.Domain }},
+	{"visits", "Visits", func(e HistoryEntry) string { return strconv.Itoa(e.VisitCount) }},
+	{"typed", "Typed", func(e HistoryEntry) string { return strconv.Itoa(e.TypedCount) }},
+	{"hidden", "Hidden", func(e HistoryEntry) string { return strconv.FormatBool(e.Hidden) }},
+	{"first", "FirstVisitedAt", func(e HistoryEntry) string { return formatTime(e.FirstVisitedAt) }},
+	{"last", "LastVisitedAt", func(e HistoryEntry) string { return formatTime(e.LastVisitedAt) }},
+	{"dwell", "Dwell", func(e HistoryEntry) string {
+		return (time.Duration(e.DwellSeconds) * time.Second).String()
+	}},
+	{"score", "Score", func(e HistoryEntry) string { return strconv.FormatFloat(e.Score, 'f', -1, 64) }},
+	{"profile", "Profile", func(e HistoryEntry) string { return e.Profile }},
+	{"source", "Source", func(e HistoryEntry) string { return e.Source }},
+	{"icon", "Icon", func(e HistoryEntry) string { return e.Icon }},
+}
+
+func findHistoryColumns(names []string) ([]historyColumn, error) {
+	var columns []historyColumn
+	for _, name := range names {
+		found := false
+		for _, column := range historyColumns {
+			if column.Name == strings.ToLower(strings.TrimSpace(name)) {
+				columns = append(columns, column)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			var valid []string
+			for _, column := range historyColumns {
+				valid = append(valid, column.Name)
+			}
+			return nil, fmt.Errorf("unknown column: %s (valid: %s)", name, strings.Join(valid, ", "))
+		}
+	}
+
+	return columns, nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+// frecencyHalfLife is the age at which a visit counts for half as much.
+const frecencyHalfLife = 14 * 24 * time.Hour
+
+// frecency combines how often and how recently a url was visited, counting
+// typed visits twice.
+func frecency(visitCount int, typedCount int, lastVisit time.Time, now time.Time) float64 {
+	age := now.Sub(lastVisit)
+	if age < 0 {
+		age = 0
+	}
+
+	return float64(visitCount+2*typedCount) * math.Pow(0.5, float64(age)/float64(frecencyHalfLife))
+}
+
+func roundScore(score float64) float64 {
+	return math.Round(score*1000) / 1000
+}
+
+// historySearch holds the filters and ordering shared by the commands
+// reading urls from the History database.
+type historySearch struct {
+	timeRange
+	Query string
+	Fuzzy string
+	Sort  string
+	Limit int
+
+	// shortcuts counts the hits of the omnibox shortcuts matching the fuzzy
+	// pattern by url, see withShortcuts
+	shortcuts map[string]int
+}
+
+func (s *historySearch) register(cmd *cobra.Command, limit int) {
+	cmd.Flags().IntVarP(&s.Limit, "limit", "l", limit, "limit")
+	cmd.Flags().StringVarP(&s.Query, "query", "q", "", "search query")
+	cmd.Flags().StringVar(&s.Fuzzy, "fuzzy", "", "fuzzy match titles and urls, best matches first, weighed by frecency with --sort frecency")
+	cmd.Flags().StringVar(&s.Sort, "sort", "recent", "sort order (frecency, recent, visits, typed)")
+	s.timeRange.register(cmd)
+}
+
+// ranked reports whether results are ordered in Go rather than by the query.
+func (s historySearch) ranked() bool {
+	return s.Sort == "frecency" || s.Fuzzy != ""
+}
+
+func (s historySearch) build() (string, []interface{}, error) {
+	// ranked searches read every url, so visits are only summed up for the
+	// urls left once ranked, see visitDetails
+	firstVisit := "COALESCE((SELECT MIN(visit_time) FROM visits WHERE visits.url = urls.id), 0)"
+	dwell := "COALESCE((SELECT SUM(visit_duration) FROM visits WHERE visits.url = urls.id), 0)"
+	if s.ranked() {
+		firstVisit, dwell = "0", "0"
+	}
+
+	sb := sb.NewSelectBuilder()
+	sb.Select(
+		"urls.id",
+		"urls.url",
+		"urls.title",
+		"urls.visit_count",
+		"urls.typed_count",
+		"urls.hidden",
+		firstVisit,
+		"urls.last_visit_time",
+		dwell,
+	)
+	sb.From("urls")
+
+	switch s.Sort {
+	case "recent", "frecency":
+		sb.OrderBy("urls.last_visit_time DESC")
+	case "visits":
+		sb.OrderBy("urls.visit_count DESC", "urls.last_visit_time DESC")
+	case "typed":
+		sb.OrderBy("urls.typed_count DESC", "urls.last_visit_time DESC")
+	default:
+		return "", nil, fmt.Errorf("unknown sort: %s", s.Sort)
+	}
+
+	// frecency and fuzzy matching rank the candidates after the query, so
+	// the limit is applied afterwards
+	if s.Limit > 0 && !s.ranked() {
+		sb.Limit(s.Limit)
+	}
+
+	if len(s.Query) > 0 {
+		terms, err := parseHistoryQuery(s.Query)
+		if err != nil {
+			return "", nil, err
+		}
+
+		conditions, err := compileHistoryQuery(terms, sb)
+		if err != nil {
+			return "", nil, err
+		}
+		sb.Where(conditions...)
+	}
+
+	if err := s.timeRange.where(sb, "urls.last_visit_time"); err != nil {
+		return "", nil, err
+	}
+
+	sql, args := sb.Build()
+	return sql, args, nil
+}
+
+func scanHistoryEntry(rows *sql.Rows, location *time.Location) (HistoryEntry, error) {
+	var entry HistoryEntry
+	var firstVisitTime, lastVisitTime, dwell int64
+	if err := rows.Scan(&entry.ID, &entry.URL, &entry.Title, &entry.VisitCount, &entry.TypedCount, &entry.Hidden, &firstVisitTime, &lastVisitTime, &dwell); err != nil {
+		return HistoryEntry{}, fmt.Errorf("failed to scan: %w", err)
+	}
+
+	entry.Domain = urlDomain(entry.URL)
+	entry.FirstVisitedAt = chromeTime(firstVisitTime).In(location)
+	entry.LastVisitedAt = chromeTime(lastVisitTime).In(location)
+	entry.DwellSeconds = float64(dwell) / 1e6
+
+	return entry, nil
+}
+
+func (s historySearch) run(db *sql.DB, location *time.Location) ([]HistoryEntry, error) {
+	query, args, err := s.build()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []HistoryEntry
+	for rows.Next() {
+		entry, err := scanHistoryEntry(rows, location)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+
+	entries = s.rank(entries)
+	if s.ranked() {
+		if err := visitDetails(db, entries, location); err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// visitDetails fills the first visit and dwell time of entries from their
+// visits, in batches to stay below the limit of query parameters.
+func visitDetails(db *sql.DB, entries []HistoryEntry, location *time.Location) error {
+	const batchSize = 500
+
+	index := make(map[int]int, len(entries))
+	for i, entry := range entries {
+		index[entry.ID] = i
+	}
+
+	for start := 0; start < len(entries); start += batchSize {
+		var ids []interface{}
+		for _, entry := range entries[start:min(start+batchSize, len(entries))] {
+			ids = append(ids, entry.ID)
+		}
+
+		query := sb.NewSelectBuilder()
+		query.Select("url", "MIN(visit_time)", "COALESCE(SUM(visit_duration), 0)")
+		query.From("visits")
+		query.Where(query.In("url", ids...))
+		query.GroupBy("url")
+
+		statement, args := query.Build()
+		rows, err := db.Query(statement, args...)
+		if err != nil {
+			return fmt.Errorf("failed to query visits: %w", err)
+		}
+
+		for rows.Next() {
+			var id int
+			var firstVisitTime, dwell int64
+			if err := rows.Scan(&id, &firstVisitTime, &dwell); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan: %w", err)
+			}
+
+			if i, ok := index[id]; ok {
+				entries[i].FirstVisitedAt = chromeTime(firstVisitTime).In(location)
+				entries[i].DwellSeconds = float64(dwell) / 1e6
+			}
+		}
+
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return fmt.Errorf("failed to query visits: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// each calls fn with every matching entry, streaming them from the query
+// unless they have to be ranked first.
+func (s historySearch) each(db *sql.DB, location *time.Location, fn func(entry HistoryEntry) error) error {
+	if s.ranked() {
+		entries, err := s.run(db, location)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	query, args, err := s.build()
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanHistoryEntry(rows, location)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query: %w", err)
+	}
+
+	return nil
+}
+
+func (s historySearch) rank(entries []HistoryEntry) []HistoryEntry {
+	if s.Sort == "frecency" {
+		now := time.Now()
+		for i, entry := range entries {
+			entries[i].Score = roundScore(frecency(entry.VisitCount, entry.TypedCount, entry.LastVisitedAt, now))
+		}
+
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Score > entries[j].Score
+		})
+	}
+
+	if s.Fuzzy != "" {
+		var matches []HistoryEntry
+		for _, entry := range entries {
+			titleScore, titleOk := fuzzyScore(s.Fuzzy, entry.Title)
+			urlScore, urlOk := fuzzyScore(s.Fuzzy, entry.URL)
+			hits, shortcut := s.shortcuts[entry.URL]
+			if !titleOk && !urlOk && !shortcut {
+				continue
+			}
+
+			// with --sort frecency, matches are weighed by their frecency
+			// instead of being ordered by it
+			score := float64(max(titleScore, urlScore))
+			if s.Sort == "frecency" {
+				score *= 1 + math.Log1p(entry.Score)
+			}
+
+			entry.Score = roundScore(score)
+			if shortcut {
+				entry.Score += float64(fuzzyBonusShortcut + hits)
+			}
+			matches = append(matches, entry)
+		}
+
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].Score > matches[j].Score
+		})
+		entries = matches
+	}
+
+	if s.ranked() && s.Limit > 0 && len(entries) > s.Limit {
+		entries = entries[:s.Limit]
+	}
+
+	return entries
+}
+
+// merge orders entries read from several profiles as a single query would.
+func (s historySearch) merge(entries []HistoryEntry) []HistoryEntry {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		switch {
+		case s.ranked():
+			return a.Score > b.Score
+		case s.Sort == "visits" && a.VisitCount != b.VisitCount:
+			return a.VisitCount > b.VisitCount
+		case s.Sort == "typed" && a.TypedCount != b.TypedCount:
+			return a.TypedCount > b.TypedCount
+		}
+
+		return a.LastVisitedAt.After(b.LastVisitedAt)
+	})
+
+	if s.Limit > 0 && len(entries) > s.Limit {
+		entries = entries[:s.Limit]
+	}
+
+	return entries
+}
+
+func printHistoryEntries(entries []HistoryEntry, columns []historyColumn, asJson bool) error {
+	if asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(entries)
+	}
+
+	var printer tableprinter.TablePrinter
+	if !isatty.IsTerminal(os.Stdout.Fd()) {
+		printer = tableprinter.New(os.Stdout, false, 0)
+	} else {
+		w, _, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			return err
+		}
+
+		printer = tableprinter.New(os.Stdout, true, w)
+	}
+
+	var header []string
+	for _, column := range columns {
+		header = append(header, column.Header)
+	}
+
+	printer.AddHeader(header)
+	for _, entry := range entries {
+		for _, column := range columns {
+			printer.AddField(column.Value(entry))
+		}
+		printer.EndRow()
+	}
+
+	return printer.Render()
 }
 
 func NewCmdHistory() *cobra.Command {
 	var flags struct {
-		query string
-		limit int
-		json  bool
+		historySearch
+		columns  []string
+		tz       string
+		archive  bool
+		sources  []string
+		iconsDir string
+		json     bool
 	}
 
 	cmd := &cobra.Command{
 		Use:   "history",
 		Short: "Search history",
+		Long:  "Search history.\n\n" + historyQueryHelp,
 		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			dbFile, err := os.Open(historyPath)
+			location, err := loadLocation(flags.tz)
 			if err != nil {
-				return fmt.Errorf("failed to open db file: %w", err)
+				return err
 			}
-			defer dbFile.Close()
 
-			tempfile, err := os.CreateTemp("", "arc-history-*.sqlite")
+			sources, err := loadHistorySources(flags.sources, flags.archive)
 			if err != nil {
-				return fmt.Errorf("failed to create tempfile: %w", err)
+				return err
 			}
-			defer os.Remove(tempfile.Name())
 
-			if _, err := io.Copy(tempfile, dbFile); err != nil {
-				return fmt.Errorf("failed to copy db file: %w", err)
+			columnNames := flags.columns
+			if !cmd.Flags().Changed("columns") && flags.ranked() {
+				columnNames = append(columnNames, "score")
 			}
-
-			db, err := sql.Open("sqlite", tempfile.Name())
-			if err != nil {
-				return fmt.Errorf("failed to open db: %w", err)
+			if !cmd.Flags().Changed("columns") && profileFlag == "all" {
+				columnNames = append(columnNames, "profile")
 			}
-
-			sb := sb.NewSelectBuilder()
-			sb.Select("id", "url", "title", sb.As("datetime(last_visit_time / 1000000 + (strftime('%s', '1601-01-01')), 'unixepoch', 'localtime')", "lastVisitedAt"))
-			sb.From("urls")
-			sb.GroupBy("url")
-			sb.OrderBy("last_visit_time DESC")
-
-			if flags.limit > 0 {
-				sb.Limit(flags.limit)
+			if !cmd.Flags().Changed("columns") && len(sources) > 1 {
+				columnNames = append(columnNames, "source")
 			}
 
-			if len(flags.query) > 0 {
-				sb.Where(sb.Or(
-					sb.Like("url", fmt.Sprintf("%%%s%%", flags.query)),
-					sb.Like("title", fmt.Sprintf("%%%s%%", flags.query)),
-				))
+			columns, err := findHistoryColumns(columnNames)
+			if err != nil {
+				return err
 			}
 
-			sql, sqlArgs := sb.Build()
-			rows, err := db.Query(sql, sqlArgs...)
-			if err != nil {
-				return fmt.Errorf("failed to query: %w", err)
+			var icons *iconCache
+			if flags.iconsDir != "" {
+				if icons, err = newIconCache(flags.iconsDir); err != nil {
+					return err
+				}
+				defer icons.Close()
 			}
-			defer rows.Close()
 
 			var entries []HistoryEntry
-			for rows.Next() {
-				var entry HistoryEntry
-				if err := rows.Scan(&entry.ID, &entry.URL, &entry.Title, &entry.LastVisitedAt); err != nil {
-					return fmt.Errorf("failed to scan: %w", err)
+			for _, name := range historySourceNames {
+				source, ok := sources[name]
+				if !ok {
+					continue
 				}
-				entries = append(entries, entry)
-			}
 
-			if flags.json {
-				encoder := json.NewEncoder(os.Stdout)
-				encoder.SetIndent("", "  ")
-				encoder.SetEscapeHTML(false)
-				return encoder.Encode(entries)
-			}
+				if err := source.Each(func(profile string, db *sql.DB) error {
+					search := flags.historySearch
+					var faviconsPath string
+					if files, ok := source.(profileFileSource); ok {
+						shortcutsPath, err := files.ProfileFile(profile, "Shortcuts")
+						if err != nil {
+							return err
+						}
 
-			var printer tableprinter.TablePrinter
-			if !isatty.IsTerminal(os.Stdout.Fd()) {
-				printer = tableprinter.New(os.Stdout, false, 0)
-			} else {
-				w, _, err := term.GetSize(int(os.Stdout.Fd()))
-				if err != nil {
+						if search, err = search.withShortcuts(shortcutsPath); err != nil {
+							return err
+						}
+
+						if icons != nil {
+							if faviconsPath, err = files.ProfileFile(profile, "Favicons"); err != nil {
+								return err
+							}
+						}
+					}
+
+					profileEntries, err := search.run(db, location)
+					if err != nil {
+						return err
+					}
+
+					for i := range profileEntries {
+						profileEntries[i].Profile = profile
+						profileEntries[i].Source = name
+						if faviconsPath != "" {
+							if profileEntries[i].Icon, err = icons.Path(faviconsPath, profileEntries[i].URL); err != nil {
+								return err
+							}
+						}
+					}
+					entries = append(entries, profileEntries...)
+					return nil
+				}); err != nil {
 					return err
 				}
+			}
 
-				printer = tableprinter.New(os.Stdout, true, w)
+			// other browsers are merged into a single timeline
+			if len(sources) > 1 {
+				entries = dedupeHistory(entries)
 			}
 
-			printer.AddHeader([]string{"URL", "Title", "LastVisitedAt"})
-			for _, entry := range entries {
-				printer.AddField(entry.URL)
-				printer.AddField(entry.Title)
-				printer.AddField(entry.LastVisitedAt)
-				printer.EndRow()
+			if profileFlag == "all" || len(sources) > 1 {
+				entries = flags.historySearch.merge(entries)
 			}
 
-			return printer.Render()
+			return printHistoryEntries(entries, columns, flags.json)
 		},
 	}
 
-	cmd.Flags().IntVarP(&flags.limit, "limit", "l", 100, "limit")
-	cmd.Flags().StringVarP(&flags.query, "query", "q", "", "query")
+	cmd.AddCommand(NewCmdHistoryVisits())
+	cmd.AddCommand(NewCmdHistorySearches())
+	cmd.AddCommand(NewCmdHistoryStats())
+	cmd.AddCommand(NewCmdHistoryTrail())
+	cmd.AddCommand(NewCmdHistorySync())
+	cmd.AddCommand(NewCmdHistoryFind())
+	cmd.AddCommand(NewCmdHistoryDelete())
+	cmd.AddCommand(NewCmdHistoryExport())
+	cmd.AddCommand(NewCmdHistoryTop())
+	cmd.AddCommand(NewCmdHistoryShortcuts())
+
+	flags.historySearch.register(cmd, 100)
+	cmd.Flags().StringSliceVar(&flags.columns, "columns", []string{"url", "title", "last"}, "table columns (id, url, title, domain, visits, typed, hidden, first, last, dwell, score, profile, source, icon)")
+	cmd.Flags().StringVar(&flags.tz, "tz", "Local", "timezone used to display timestamps")
+	cmd.Flags().BoolVar(&flags.archive, "archive", false, "search the archive kept by arc history sync")
+	cmd.Flags().StringSliceVar(&flags.sources, "source", []string{"arc"}, "browsers to search (all, "+strings.Join(historySourceNames, ", ")+")")
+	cmd.Flags().StringVar(&flags.iconsDir, "icons-dir", "", "write favicons to this dir and include their paths in the output")
 	cmd.Flags().BoolVar(&flags.json, "json", false, "output as json")
 
 	return cmd
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cli/go-gh/v2/pkg/tableprinter"
+	"github.com/mattn/go-isatty"
+	"github.com/pomdtr/arc/sidebar"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	bookmarkTokenPattern = regexp.MustCompile(`(?is)<h3[^>]*>(.*?)</h3>|<a\s([^>]*)>(.*?)</a>|<dl[^>]*>|</dl>`)
+	bookmarkHrefPattern  = regexp.MustCompile(`(?is)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+	htmlTagPattern       = regexp.MustCompile(`<[^>]*>`)
+)
+
+// parseBookmarksHTML reads a Netscape bookmark file into a tree of folders
+// and tabs.
+func parseBookmarksHTML(content []byte) ([]sidebar.Node, error) {
+	root := &sidebar.Node{Type: "folder"}
+	stack := []*sidebar.Node{root}
+	var pending *sidebar.Node
+	opened := false
+
+	text := func(raw []byte) string {
+		return strings.TrimSpace(html.UnescapeString(htmlTagPattern.ReplaceAllString(string(raw), "")))
+	}
+
+	for _, match := range bookmarkTokenPattern.FindAllSubmatchIndex(content, -1) {
+		token := content[match[0]:match[1]]
+		top := stack[len(stack)-1]
+
+		switch {
+		case match[2] >= 0:
+			top.Children = append(top.Children, sidebar.Node{
+				Type:  "folder",
+				Title: text(content[match[2]:match[3]]),
+			})
+			pending = &top.Children[len(top.Children)-1]
+		case match[4] >= 0:
+			href := bookmarkHrefPattern.FindSubmatch(content[match[4]:match[5]])
+			if href == nil {
+				continue
+			}
+
+			pending = nil
+			link := html.UnescapeString(string(bytes.Join(href[1:], nil)))
+			title := text(content[match[6]:match[7]])
+			if title == "" {
+				title = link
+			}
+
+			top.Children = append(top.Children, sidebar.Node{
+				Type:  "tab",
+				Title: title,
+				URL:   link,
+			})
+		case bytes.HasPrefix(bytes.ToLower(token), []byte("</")):
+			if len(stack) == 1 {
+				continue
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if pending != nil {
+				stack = append(stack, pending)
+				pending = nil
+			} else if opened {
+				// a list without a heading, keep its items in the current folder
+				stack = append(stack, top)
+			}
+			opened = true
+		}
+	}
+
+	if !opened {
+		return nil, fmt.Errorf("not a bookmark file")
+	}
+
+	return root.Children, nil
+}
+
+// parseURLList reads one url per line, ignoring blank lines and comments.
+func parseURLList(content []byte) []sidebar.Node {
+	var nodes []sidebar.Node
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		nodes = append(nodes, sidebar.Node{
+			Type:  "tab",
+			Title: line,
+			URL:   line,
+		})
+	}
+
+	return nodes
+}
+
+type importStep struct {
+	Action string
+	Folder string
+	Title  string
+	URL    string
+}
+
+func planImport(nodes []sidebar.Node, pinned map[string]bool) []importStep {
+	var steps []importStep
+	seen := make(map[string]bool)
+
+	var walk func(nodes []sidebar.Node, folder []string)
+	walk = func(nodes []sidebar.Node, folder []string) {
+		for _, node := range nodes {
+			if node.Type == "folder" {
+				walk(node.Children, append(folder[:len(folder):len(folder)], node.Title))
+				continue
+			}
+
+			action := "pin"
+			if checkImportURL(node.URL) != nil {
+				action = "invalid"
+			} else if pinned[node.URL] || seen[node.URL] {
+				action = "skip"
+			}
+			seen[node.URL] = true
+
+			steps = append(steps, importStep{
+				Action: action,
+				Folder: strings.Join(folder, "/"),
+				Title:  node.Title,
+				URL:    node.URL,
+			})
+		}
+	}
+
+	walk(nodes, nil)
+	return steps
+}
+
+// pinnedURLs returns the urls pinned in the space with the given title,
+// according to the sidebar file.
+func pinnedURLs(spaceTitle string) (map[string]bool, error) {
+	s, err := loadSidebar()
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make(map[string]bool)
+	var walk func(nodes []sidebar.Node)
+	walk = func(nodes []sidebar.Node) {
+		for _, node := range nodes {
+			if node.Type == "folder" {
+				walk(node.Children)
+				continue
+			}
+			urls[node.URL] = true
+		}
+	}
+
+	for _, space := range s.Tree().Spaces {
+		if strings.EqualFold(space.Title, spaceTitle) {
+			walk(space.Pinned)
+		}
+	}
+
+	return urls, nil
+}
+
+// pinNewTab opens a url in a space and pins it with Arc's keyboard
+// shortcut, as the scripting dictionary has no way to pin tabs. The shortcut
+// applies to the active tab, so it is only sent once the new tab is.
+func pinNewTab(url string, space int) error {
+	if _, err := runApplescript(`on run argv
+		tell application "Arc"
+			tell space ((item 2 of argv) as integer)
+				set newTab to make new tab with properties {URL:item 1 of argv}
+			end tell
+			set tabID to id of newTab
+			tell newTab to select
+			activate
+			repeat 50 times
+				if id of active tab of front window is tabID then exit repeat
+				delay 0.1
+			end repeat
+			if id of active tab of front window is not tabID then error "the new tab did not become active, it was left unpinned"
+		end tell
+		tell application "System Events" to keystroke "d" using command down
+	end run`, url, strconv.Itoa(space)); err != nil {
+		return fmt.Errorf("failed to pin %s: %w", url, err)
+	}
+
+	return nil
+}
+
+// checkImportURL rejects urls that are not web pages, as bookmark files may
+// come from anyone.
+func checkImportURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid url %q: %w", rawURL, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("refusing to import %q, only http and https urls are supported", rawURL)
+	}
+
+	return nil
+}
+
+func NewCmdImport() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import data into Arc",
+	}
+
+	cmd.AddCommand(NewCmdImportBookmarks())
+
+	return cmd
+}
+
+func NewCmdImportBookmarks() *cobra.Command {
+	var flags struct {
+		Space   string
+		DryRun  bool
+		Flatten bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "bookmarks <file>",
+		Short: "Import a bookmarks file or a list of urls as pinned tabs",
+		Long: `Import a Netscape bookmarks file or a list of urls as pinned tabs.
+
+Arc cannot create folders from scripts, so importing bookmarks inside
+folders requires --flatten, which pins them at the top level of the space.
+Urls already pinned in the space are skipped, and urls other than http and
+https are never imported.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			content, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+
+			var nodes []sidebar.Node
+			if bytes.Contains(bytes.ToLower(content), []byte("<dl")) {
+				nodes, err = parseBookmarksHTML(content)
+				if err != nil {
+					return err
+				}
+			} else {
+				nodes = parseURLList(content)
+			}
+
+			space, err := resolveSpace(flags.Space)
+			if err != nil {
+				return err
+			}
+
+			pinned, err := pinnedURLs(space.Title)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "could not read pinned tabs, duplicates will not be skipped: %s\n", err)
+			}
+
+			steps := planImport(nodes, pinned)
+
+			var printer tableprinter.TablePrinter
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				printer = tableprinter.New(os.Stdout, false, 0)
+			} else {
+				w, _, err := term.GetSize(int(os.Stdout.Fd()))
+				if err != nil {
+					return err
+				}
+
+				printer = tableprinter.New(os.Stdout, true, w)
+			}
+
+			printer.AddHeader([]string{"Action", "Folder", "Title", "URL"})
+			for _, step := range steps {
+				printer.AddField(step.Action)
+				printer.AddField(step.Folder)
+				printer.AddField(step.Title)
+				printer.AddField(step.URL)
+				printer.EndRow()
+			}
+
+			if err := printer.Render(); err != nil {
+				return err
+			}
+
+			if flags.DryRun {
+				return nil
+			}
+
+			for _, step := range steps {
+				if step.Action == "pin" && step.Folder != "" && !flags.Flatten {
+					return errors.New("arc cannot create folders, pass --flatten to pin the bookmarks inside folders at the top level")
+				}
+			}
+
+			for _, step := range steps {
+				if step.Action != "pin" {
+					continue
+				}
+
+				if err := pinNewTab(step.URL, space.ID); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Space, "space", "", "space to import the bookmarks in")
+	cmd.Flags().BoolVar(&flags.DryRun, "dry-run", false, "only print what would be imported")
+	cmd.Flags().BoolVar(&flags.Flatten, "flatten", false, "pin bookmarks inside folders at the top level")
+	cmd.MarkFlagRequired("space")
+	return cmd
+}
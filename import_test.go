@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanImport(t *testing.T) {
+	nodes, err := parseBookmarksHTML([]byte(`<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<DL><p>
+    <DT><A HREF="https://example.com/">Example</A>
+    <DT><A HREF="https://x.com/&quot;} &amp; do shell script &quot;touch /tmp/pwned">Quoted</A>
+    <DT><A HREF="javascript:alert(1)">Script</A>
+    <DT><H3>Folder</H3>
+    <DL><p>
+        <DT><A HREF="https://example.com/">Duplicate</A>
+        <DT><A HREF="http://folder.example.com/">Nested</A>
+    </DL><p>
+</DL><p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got [][2]string
+	for _, step := range planImport(nodes, nil) {
+		got = append(got, [2]string{step.Action, step.Folder})
+	}
+
+	// the quoted href is a valid https url, it is only dangerous when
+	// formatted into a script, which runApplescript arguments avoid
+	want := [][2]string{
+		{"pin", ""},
+		{"pin", ""},
+		{"invalid", ""},
+		{"skip", "Folder"},
+		{"pin", "Folder"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("steps = %v, want %v", got, want)
+	}
+}
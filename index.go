@@ -0,0 +1,451 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	sb "github.com/huandu/go-sqlbuilder"
+	"github.com/spf13/cobra"
+)
+
+// searchIndexVersion is bumped whenever the schema of the index changes,
+// existing indexes are then rebuilt from scratch.
+const searchIndexVersion = 3
+
+// searchCandidates bounds the matches ranked when results are limited.
+// Ranking scores every match, which takes over 100ms for a common term on a
+// large history, so only the most recently visited matches are ranked.
+const searchCandidates = 1000
+
+// Pages are indexed in the order of their last visit, which lets searches
+// find the most recent matches through the rowid. page_ids maps the ids of
+// urls to the rowids of their pages.
+const searchIndexSchema = `
+CREATE VIRTUAL TABLE pages USING fts5(
+	url,
+	title,
+	domain,
+	id UNINDEXED,
+	visit_count UNINDEXED,
+	typed_count UNINDEXED,
+	hidden UNINDEXED,
+	first_visit_time UNINDEXED,
+	last_visit_time UNINDEXED,
+	dwell UNINDEXED,
+	tokenize = 'unicode61 remove_diacritics 2',
+	prefix = '2 3'
+);
+CREATE TABLE page_ids (
+	id INTEGER PRIMARY KEY,
+	page INTEGER NOT NULL
+);
+CREATE TABLE state (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);`
+
+func searchIndexPath(historyPath string) (string, error) {
+	cacheDir, err := appCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(cacheDir, "index", snapshotHash(historyPath)+".sqlite"), nil
+}
+
+// openSearchIndex opens the full-text index of a History database, which
+// lives in the cache dir and is refreshed from the source before use.
+func openSearchIndex(historyPath string) (*sql.DB, error) {
+	indexPath, err := searchIndexPath(historyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(indexPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", indexPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index: %w", err)
+	}
+
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read index version: %w", err)
+	}
+
+	if version != searchIndexVersion {
+		if _, err := db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS pages; DROP TABLE IF EXISTS page_ids; DROP TABLE IF EXISTS state; %s PRAGMA user_version = %d;", searchIndexSchema, searchIndexVersion)); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create index: %w", err)
+		}
+	}
+
+	if err := refreshSearchIndex(db, historyPath); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// removeSearchIndex removes the index of a History database, for when it
+// holds pages deleted from it.
+func removeSearchIndex(historyPath string) error {
+	indexPath, err := searchIndexPath(historyPath)
+	if err != nil {
+		return err
+	}
+
+	for _, suffix := range snapshotSuffixes {
+		if err := os.Remove(indexPath + suffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove index: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// refreshSearchIndex indexes the urls visited since the last refresh. Urls
+// removed from the source, by expiry or deletion, trigger a full rebuild.
+func refreshSearchIndex(db *sql.DB, historyPath string) error {
+	stamp, err := snapshotStamp(historyPath)
+	if err != nil {
+		return err
+	}
+
+	var indexedStamp string
+	var mark, indexCount int64
+	db.QueryRow("SELECT value FROM state WHERE key = 'stamp'").Scan(&indexedStamp)
+	db.QueryRow("SELECT CAST(value AS INTEGER) FROM state WHERE key = 'last_visit_time'").Scan(&mark)
+	db.QueryRow("SELECT CAST(value AS INTEGER) FROM state WHERE key = 'count'").Scan(&indexCount)
+
+	// the common case of an unchanged source skips opening it altogether
+	if indexedStamp == stamp {
+		return nil
+	}
+
+	// only the urls visited since the last refresh are read, which is quick
+	// enough to do in place rather than through a copy of the source
+	source, cleanup, err := openInPlace(historyPath)
+	if err != nil {
+		if source, cleanup, err = openSnapshot(historyPath); err != nil {
+			return err
+		}
+	}
+	defer cleanup()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to refresh index: %w", err)
+	}
+	defer tx.Rollback()
+
+	// visits made while indexing are picked up by the next refresh
+	var lastVisitTime int64
+	if err := source.QueryRow("SELECT COALESCE(MAX(visit_time), 0) FROM visits").Scan(&lastVisitTime); err != nil {
+		return fmt.Errorf("failed to query: %w", err)
+	}
+
+	added, err := indexURLs(tx, source, mark)
+	if err != nil {
+		return err
+	}
+	indexCount += added
+
+	// counting the pages of the index would read all of it, so the count is
+	// kept along with the state
+	var sourceCount int64
+	if err := source.QueryRow("SELECT COUNT(*) FROM urls").Scan(&sourceCount); err != nil {
+		return fmt.Errorf("failed to query: %w", err)
+	}
+
+	if sourceCount != indexCount {
+		if _, err := tx.Exec("DELETE FROM pages; DELETE FROM page_ids"); err != nil {
+			return fmt.Errorf("failed to clear index: %w", err)
+		}
+
+		if indexCount, err = indexURLs(tx, source, 0); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`INSERT INTO state (key, value) VALUES ('stamp', ?), ('last_visit_time', ?), ('count', ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value`, stamp, lastVisitTime, indexCount); err != nil {
+		return fmt.Errorf("failed to save index state: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// indexURLs copies the urls visited after since into the index, or all of
+// them when since is 0, returning the number of pages added. Pages of urls
+// visited again are removed and added back, after the others.
+func indexURLs(tx *sql.Tx, source *sql.DB, since int64) (int64, error) {
+	// urls are found through the index on visit times, History has none on
+	// the last visit time of urls
+	where := "urls.id IN (SELECT url FROM visits WHERE visit_time > ?)"
+	if since == 0 {
+		where = "? = 0"
+	}
+
+	rows, err := source.Query(`SELECT
+		urls.id,
+		urls.url,
+		urls.title,
+		urls.visit_count,
+		urls.typed_count,
+		urls.hidden,
+		COALESCE((SELECT MIN(visit_time) FROM visits WHERE visits.url = urls.id), 0),
+		urls.last_visit_time,
+		COALESCE((SELECT SUM(visit_duration) FROM visits WHERE visits.url = urls.id), 0)
+		FROM urls WHERE `+where+`
+		ORDER BY urls.last_visit_time, urls.id`, since)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query: %w", err)
+	}
+	defer rows.Close()
+
+	remove, err := tx.Prepare("DELETE FROM pages WHERE rowid = (SELECT page FROM page_ids WHERE id = ?)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to update index: %w", err)
+	}
+	defer remove.Close()
+
+	insert, err := tx.Prepare(`INSERT INTO pages (id, url, title, domain, visit_count, typed_count, hidden, first_visit_time, last_visit_time, dwell)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update index: %w", err)
+	}
+	defer insert.Close()
+
+	mapID, err := tx.Prepare("INSERT INTO page_ids (id, page) VALUES (?, ?) ON CONFLICT (id) DO UPDATE SET page = excluded.page")
+	if err != nil {
+		return 0, fmt.Errorf("failed to update index: %w", err)
+	}
+	defer mapID.Close()
+
+	var added int64
+	for rows.Next() {
+		var id, visitCount, typedCount, firstVisitTime, lastVisitTime, dwell int64
+		var url, title string
+		var hidden bool
+		if err := rows.Scan(&id, &url, &title, &visitCount, &typedCount, &hidden, &firstVisitTime, &lastVisitTime, &dwell); err != nil {
+			return 0, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		removed, err := remove.Exec(id)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update index: %w", err)
+		}
+
+		inserted, err := insert.Exec(id, url, title, urlDomain(url), visitCount, typedCount, hidden, firstVisitTime, lastVisitTime, dwell)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update index: %w", err)
+		}
+
+		page, err := inserted.LastInsertId()
+		if err != nil {
+			return 0, fmt.Errorf("failed to update index: %w", err)
+		}
+
+		if _, err := mapID.Exec(id, page); err != nil {
+			return 0, fmt.Errorf("failed to update index: %w", err)
+		}
+
+		if n, _ := removed.RowsAffected(); n == 0 {
+			added++
+		}
+	}
+
+	return added, rows.Err()
+}
+
+// ftsQuery turns user input into an FTS5 match expression. Every term is
+// quoted so that punctuation in urls is not read as query syntax, quoted
+// phrases are kept together and a trailing * matches prefixes.
+func ftsQuery(input string) (string, error) {
+	var terms []string
+	rest := strings.TrimSpace(input)
+	for rest != "" {
+		var term string
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated phrase: %s", rest)
+			}
+			term, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			end := strings.IndexAny(rest, " \t")
+			if end < 0 {
+				end = len(rest)
+			}
+			term, rest = rest[:end], rest[end:]
+		}
+
+		prefix := strings.HasSuffix(term, "*") || strings.HasPrefix(rest, "*")
+		term = strings.TrimSuffix(term, "*")
+		rest = strings.TrimLeft(rest, "* \t")
+		if strings.TrimSpace(term) == "" {
+			continue
+		}
+
+		quoted := `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+		if prefix {
+			quoted += "*"
+		}
+		terms = append(terms, quoted)
+	}
+
+	if len(terms) == 0 {
+		return "", fmt.Errorf("empty query")
+	}
+
+	return strings.Join(terms, " "), nil
+}
+
+// searchIndex returns the pages matching an FTS5 expression, most relevant
+// first.
+func searchIndex(db *sql.DB, match string, timeRange timeRange, limit int, location *time.Location) ([]HistoryEntry, error) {
+	query := sb.NewSelectBuilder()
+	query.Select("id", "url", "title", "visit_count", "typed_count", "hidden", "first_visit_time", "last_visit_time", "dwell", "bm25(pages, 5.0, 10.0, 2.0) AS score")
+	query.From("pages")
+	query.Where("pages MATCH " + query.Var(match))
+	query.OrderBy("score")
+	if err := timeRange.where(query, "last_visit_time"); err != nil {
+		return nil, err
+	}
+
+	// the most recent matches are found through the rowid without scoring
+	// them, and only pages from the oldest of them on are ranked
+	if limit > 0 {
+		candidates := sb.NewSelectBuilder()
+		candidates.Select("rowid")
+		candidates.From("pages")
+		candidates.Where("pages MATCH " + candidates.Var(match))
+		candidates.OrderBy("rowid").Desc()
+		candidates.Limit(max(limit, searchCandidates))
+		if err := timeRange.where(candidates, "last_visit_time"); err != nil {
+			return nil, err
+		}
+
+		query.Where("rowid >= (SELECT COALESCE(MIN(rowid), 0) FROM (" + query.Var(candidates) + "))")
+		query.Limit(limit)
+	}
+
+	sql, args := query.Build()
+	rows, err := db.Query(sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []HistoryEntry
+	for rows.Next() {
+		var entry HistoryEntry
+		var firstVisitTime, lastVisitTime, dwell int64
+		var score float64
+		if err := rows.Scan(&entry.ID, &entry.URL, &entry.Title, &entry.VisitCount, &entry.TypedCount, &entry.Hidden, &firstVisitTime, &lastVisitTime, &dwell, &score); err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		entry.Domain = urlDomain(entry.URL)
+		entry.FirstVisitedAt = chromeTime(firstVisitTime).In(location)
+		entry.LastVisitedAt = chromeTime(lastVisitTime).In(location)
+		entry.DwellSeconds = float64(dwell) / 1e6
+		// bm25 scores are negative, lower being better
+		entry.Score = roundScore(-score)
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query index: %w", err)
+	}
+
+	return entries, nil
+}
+
+func NewCmdHistoryFind() *cobra.Command {
+	var flags struct {
+		timeRange
+		limit   int
+		columns []string
+		tz      string
+		json    bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "find <terms>...",
+		Short: "Search history through a full-text index",
+		Long: `Search history through a full-text index.
+
+Urls, titles and domains are indexed in the cache dir, and the index is
+refreshed with new visits before searching. All terms must match, a term
+ending with * matches any word starting with it and "quoted words" match a
+phrase. Results are ranked by relevance, titles weighing the most. With a
+limit, only the 1000 most recently visited matches are ranked.`,
+		Example: `  arc history find pomdtr arc
+  arc history find 'git*'
+  arc history find '"release notes"'`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			location, err := loadLocation(flags.tz)
+			if err != nil {
+				return err
+			}
+
+			columns, err := findHistoryColumns(flags.columns)
+			if err != nil {
+				return err
+			}
+
+			match, err := ftsQuery(strings.Join(args, " "))
+			if err != nil {
+				return err
+			}
+
+			profile, err := selectedProfile()
+			if err != nil {
+				return err
+			}
+
+			historyPath, err := profile.Path("History")
+			if err != nil {
+				return err
+			}
+
+			db, err := openSearchIndex(historyPath)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			entries, err := searchIndex(db, match, flags.timeRange, flags.limit, location)
+			if err != nil {
+				return err
+			}
+
+			for i := range entries {
+				entries[i].Profile = profile.Dir
+			}
+
+			return printHistoryEntries(entries, columns, flags.json)
+		},
+	}
+
+	cmd.Flags().IntVarP(&flags.limit, "limit", "l", 20, "limit")
+	cmd.Flags().StringSliceVar(&flags.columns, "columns", []string{"url", "title", "score"}, "table columns (id, url, title, domain, visits, typed, hidden, first, last, dwell, score, profile)")
+	cmd.Flags().StringVar(&flags.tz, "tz", "Local", "timezone used to display timestamps")
+	cmd.Flags().BoolVar(&flags.json, "json", false, "output as json")
+	flags.timeRange.register(cmd)
+
+	return cmd
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFtsQuery(t *testing.T) {
+	for _, tt := range []struct {
+		input string
+		want  string
+	}{
+		{"pomdtr arc", `"pomdtr" "arc"`},
+		{"git*", `"git"*`},
+		{`"release notes" go`, `"release notes" "go"`},
+		{"github.com/pomdtr", `"github.com/pomdtr"`},
+		{`say "hi`, ""},
+		{" * ", ""},
+	} {
+		got, err := ftsQuery(tt.input)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ftsQuery(%q) = %q, want an error", tt.input, got)
+			}
+			continue
+		}
+
+		if err != nil || got != tt.want {
+			t.Errorf("ftsQuery(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestSearchIndexRefresh(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	historyPath := filepath.Join(t.TempDir(), "History")
+	writeHistoryFixture(t, historyPath, 100, "DELETE")
+
+	find := func(match string) []HistoryEntry {
+		t.Helper()
+
+		db, err := openSearchIndex(historyPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		entries, err := searchIndex(db, match, timeRange{}, 0, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return entries
+	}
+
+	history, err := sql.Open("sqlite", historyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+
+	count := func(query string) int {
+		t.Helper()
+
+		var n int
+		if err := history.QueryRow(query).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+
+		return n
+	}
+
+	githubs := "SELECT COUNT(*) FROM urls WHERE ' ' || title || ' ' LIKE '% github %' OR url LIKE '%/github/%'"
+	if entries, want := find(`"github"`), count(githubs); len(entries) != want {
+		t.Errorf("got %d github pages, want %d", len(entries), want)
+	}
+
+	for _, query := range []string{
+		"INSERT INTO urls (id, url, title, last_visit_time) VALUES (1000, 'https://new.example.com/', 'Fresh release', 13400000000000000)",
+		"INSERT INTO visits (url, visit_time) VALUES (1000, 13400000000000000)",
+	} {
+		if _, err := history.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if entries := find(`"fresh"`); len(entries) != 1 || entries[0].URL != "https://new.example.com/" {
+		t.Errorf("got %+v, want the new page", entries)
+	}
+
+	// removed urls trigger a rebuild
+	if _, err := history.Exec("DELETE FROM urls WHERE title LIKE 'github%'"); err != nil {
+		t.Fatal(err)
+	}
+
+	if entries, want := find(`"github"`), count(githubs); len(entries) != want {
+		t.Errorf("got %d github pages after deletion, want %d", len(entries), want)
+	}
+
+	db, err := openSearchIndex(historyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var indexed, pages int
+	db.QueryRow("SELECT CAST(value AS INTEGER) FROM state WHERE key = 'count'").Scan(&indexed)
+	db.QueryRow("SELECT COUNT(*) FROM pages").Scan(&pages)
+	if want := count("SELECT COUNT(*) FROM urls"); indexed != want || pages != want {
+		t.Errorf("got %d pages, %d in the state, want %d", pages, indexed, want)
+	}
+
+	// the source is read in place, only the index lives in the cache dir
+	if cached := cachedSnapshots(t); len(cached) != 0 {
+		t.Errorf("got cached snapshots %v, want none", cached)
+	}
+}
+
+func TestSearchIndexCandidates(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	// enough urls for common terms to match more than searchCandidates
+	historyPath := filepath.Join(t.TempDir(), "History")
+	writeHistoryFixture(t, historyPath, 6000, "DELETE")
+
+	history, err := sql.Open("sqlite", historyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+
+	// the oldest page is the most relevant
+	if _, err := history.Exec("UPDATE urls SET title = 'github github github' WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	top := func(limit int) int {
+		t.Helper()
+
+		db, err := openSearchIndex(historyPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		entries, err := searchIndex(db, `"github"`, timeRange{}, limit, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(entries) == 0 {
+			t.Fatal("got no entries")
+		}
+
+		return entries[0].ID
+	}
+
+	if id := top(0); id != 1 {
+		t.Errorf("got %d first without a limit, want 1", id)
+	}
+
+	if id := top(5); id == 1 {
+		t.Error("got page 1 first with a limit, want it left out of the candidates")
+	}
+
+	// a new visit makes it a candidate again
+	if _, err := history.Exec("INSERT INTO visits (url, visit_time) VALUES (1, 13400000000000000)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if id := top(5); id != 1 {
+		t.Errorf("got %d first after visiting page 1, want 1", id)
+	}
+}
+
+func TestRemoveSearchIndex(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	historyPath := filepath.Join(t.TempDir(), "History")
+	writeHistoryFixture(t, historyPath, 10, "DELETE")
+
+	db, err := openSearchIndex(historyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if err := removeSearchIndex(historyPath); err != nil {
+		t.Fatal(err)
+	}
+
+	indexPath, err := searchIndexPath(historyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files, _ := filepath.Glob(indexPath + "*"); len(files) > 0 {
+		t.Errorf("index files left behind: %v", files)
+	}
+
+	// removing a missing index is not an error
+	if err := removeSearchIndex(historyPath); err != nil {
+		t.Error(err)
+	}
+}
+
+// findBudget is the time a search may take to keep up with keystrokes.
+const findBudget = 20 * time.Millisecond
+
+// BenchmarkHistoryFind measures a search as run by a launcher on each
+// keystroke, opening the index of a History database of 200k urls. Refreshes
+// add a visit before each search, and the common term matches about one in
+// five pages. Searches slower than findBudget fail the benchmark.
+func BenchmarkHistoryFind(b *testing.B) {
+	b.Setenv("XDG_CACHE_HOME", b.TempDir())
+
+	historyPath := filepath.Join(b.TempDir(), "History")
+	writeHistoryFixture(b, historyPath, 200000, "DELETE")
+
+	history, err := sql.Open("sqlite", historyPath)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer history.Close()
+
+	find := func(b *testing.B, match string) {
+		db, err := openSearchIndex(historyPath)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		entries, err := searchIndex(db, match, timeRange{}, 20, time.UTC)
+		if err != nil {
+			b.Fatal(err)
+		}
+		db.Close()
+
+		if len(entries) == 0 {
+			b.Fatal("got no entries")
+		}
+	}
+
+	withinBudget := func(b *testing.B) {
+		if elapsed := b.Elapsed() / time.Duration(b.N); elapsed > findBudget {
+			b.Errorf("search took %s, want under %s", elapsed, findBudget)
+		}
+	}
+
+	// builds the index
+	find(b, `"golang"`)
+
+	b.Run("unchanged", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			find(b, `"golang" "rel"*`)
+		}
+		withinBudget(b)
+	})
+
+	b.Run("refresh", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			if _, err := history.Exec("INSERT INTO visits (url, visit_time) VALUES (?, 13400000000000000 + ?)", i%200000+1, i); err != nil {
+				b.Fatal(err)
+			}
+			b.StartTimer()
+
+			find(b, `"golang" "rel"*`)
+		}
+		withinBudget(b)
+	})
+
+	b.Run("common", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			find(b, `"github"`)
+		}
+		withinBudget(b)
+	})
+}
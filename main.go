@@ -10,8 +10,11 @@ import (
 	"github.com/spf13/cobra/doc"
 )
 
-func runApplescript(code string) ([]byte, error) {
-	output, err := exec.Command("osascript", "-e", code).Output()
+// runApplescript runs a script with osascript. Values read from files or
+// other untrusted input must be passed as args, which the script reads from
+// the argv of its run handler, rather than formatted into the code.
+func runApplescript(code string, args ...string) ([]byte, error) {
+	output, err := exec.Command("osascript", append([]string{"-e", code}, args...)...).Output()
 	if err != nil {
 		if exitError, ok := err.(*exec.ExitError); ok {
 			return nil, fmt.Errorf("%s", exitError.Stderr)
@@ -101,10 +104,20 @@ func main() {
 		SilenceUsage: true,
 	}
 
+	cmd.PersistentFlags().StringVar(&dataDirFlag, "data-dir", "", "Arc data dir to read from disk (defaults to $ARC_DATA_DIR, the config file, then the platform location)")
+	cmd.PersistentFlags().StringVar(&profileFlag, "profile", "", "profile to read from disk, by directory or name, or all (defaults to Default)")
+
 	cmd.AddCommand(NewCmdTab())
 	cmd.AddCommand(NewCmdSpace())
 	cmd.AddCommand(NewCmdWindow())
+	cmd.AddCommand(NewCmdSidebar())
+	cmd.AddCommand(NewCmdArchive())
+	cmd.AddCommand(NewCmdExport())
+	cmd.AddCommand(NewCmdImport())
 	cmd.AddCommand(NewCmdHistory())
+	cmd.AddCommand(NewCmdDownloads())
+	cmd.AddCommand(NewCmdFavicon())
+	cmd.AddCommand(NewCmdProfile())
 	cmd.AddCommand(NewCmdVersion())
 	cmd.AddCommand(NewDocCmd())
 
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain points the cache, data and config dirs to a temporary dir, so
+// that no test reads or removes the files of the user. Tests that look at
+// these dirs still set their own.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "arc-test-*")
+	if err != nil {
+		panic(err)
+	}
+
+	for key, name := range map[string]string{
+		"XDG_CACHE_HOME":  "cache",
+		"XDG_DATA_HOME":   "data",
+		"XDG_CONFIG_HOME": "config",
+	} {
+		os.Setenv(key, filepath.Join(dir, name))
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// useDataDir points the Arc data dir to dir for the duration of a test.
+func useDataDir(tb testing.TB, dir string) {
+	tb.Helper()
+
+	resolve := resolveDataDir
+	resolveDataDir = func() (string, error) { return dir, nil }
+	tb.Cleanup(func() { resolveDataDir = resolve })
+}
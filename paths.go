@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// dataDirFlag holds the global --data-dir flag.
+var dataDirFlag string
+
+type config struct {
+	DataDir string `json:"dataDir"`
+}
+
+func configPath() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "arc", "config.json"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home dir: %w", err)
+	}
+
+	return filepath.Join(home, ".config", "arc", "config.json"), nil
+}
+
+func loadConfig() (config, error) {
+	path, err := configPath()
+	if err != nil {
+		return config{}, err
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config{}, nil
+	}
+
+	if err != nil {
+		return config{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var cfg config
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return config{}, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// defaultDataDir returns where Arc keeps its data on the current platform.
+func defaultDataDir() (string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find home dir: %w", err)
+		}
+
+		return filepath.Join(home, "Library", "Application Support", "Arc"), nil
+	case "windows":
+		matches, _ := filepath.Glob(filepath.Join(os.Getenv("LOCALAPPDATA"), "Packages", "TheBrowserCompany.Arc_*", "LocalCache", "Local", "Arc"))
+		if len(matches) > 0 {
+			return matches[0], nil
+		}
+
+		return "", errors.New("failed to find the Arc data dir, set it with --data-dir")
+	default:
+		return "", fmt.Errorf("arc does not run on %s, point --data-dir to a copy of its data dir", runtime.GOOS)
+	}
+}
+
+// resolveDataDir picks the Arc data dir once per run.
+var resolveDataDir = sync.OnceValues(findDataDir)
+
+// findDataDir picks the Arc data dir from the --data-dir flag, the
+// ARC_DATA_DIR environment variable, the config file or the platform default,
+// in that order.
+func findDataDir() (string, error) {
+	dir := dataDirFlag
+	if dir == "" {
+		dir = os.Getenv("ARC_DATA_DIR")
+	}
+
+	if dir == "" {
+		cfg, err := loadConfig()
+		if err != nil {
+			return "", err
+		}
+		dir = cfg.DataDir
+	}
+
+	if dir == "" {
+		return defaultDataDir()
+	}
+
+	if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find home dir: %w", err)
+		}
+		dir = filepath.Join(home, rest)
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		return "", fmt.Errorf("failed to open data dir: %w", err)
+	}
+
+	return dir, nil
+}
+
+// dataPath joins a path relative to the Arc data dir.
+func dataPath(elem ...string) (string, error) {
+	dir, err := resolveDataDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(append([]string{dir}, elem...)...), nil
+}
+
+// appDataDir is where arc keeps its own long-lived files, outside of the Arc
+// data dir.
+func appDataDir() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find home dir: %w", err)
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+
+	return filepath.Join(dir, "arc"), nil
+}
+
+// appCacheDir is where arc keeps the copies and indexes it can rebuild.
+// XDG_CACHE_HOME is honored on every platform, where os.UserCacheDir ignores
+// it on darwin.
+func appCacheDir() (string, error) {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		var err error
+		if dir, err = os.UserCacheDir(); err != nil {
+			return "", fmt.Errorf("failed to find cache dir: %w", err)
+		}
+	}
+
+	return filepath.Join(dir, "arc"), nil
+}
+
+// historyArchivePath is where history of a profile is synced to, so that it
+// outlives the expiry of old visits.
+func historyArchivePath(profile Profile) (string, error) {
+	dir, err := appDataDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "history", profile.Dir+".sqlite"), nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAppCacheDir(t *testing.T) {
+	cacheHome := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheHome)
+
+	snapshotDir, err := snapshotCacheDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexPath, err := searchIndexPath("/Users/me/Library/Application Support/Arc/User Data/Default/History")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// os.UserCacheDir ignores XDG_CACHE_HOME on darwin
+	for _, path := range []string{snapshotDir, indexPath} {
+		if !strings.HasPrefix(path, filepath.Join(cacheHome, "arc")+string(filepath.Separator)) {
+			t.Errorf("%s is outside of XDG_CACHE_HOME %s", path, cacheHome)
+		}
+	}
+}
+
+func TestFindDataDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	flagDir := filepath.Join(home, "flag")
+	envDir := filepath.Join(home, "env")
+	configDir := filepath.Join(home, "config")
+	for _, dir := range []string{flagDir, envDir, configDir} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeConfig := func(t *testing.T, dataDir string) {
+		t.Helper()
+
+		configHome := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", configHome)
+		if dataDir == "" {
+			return
+		}
+
+		if err := os.MkdirAll(filepath.Join(configHome, "arc"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(configHome, "arc", "config.json"), []byte(`{"dataDir": "`+dataDir+`"}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defaultDir, defaultErr := defaultDataDir()
+
+	for _, tc := range []struct {
+		Name   string
+		Flag   string
+		Env    string
+		Config string
+		Want   string
+	}{
+		{"flag", flagDir, envDir, configDir, flagDir},
+		{"env", "", envDir, configDir, envDir},
+		{"config", "", "", configDir, configDir},
+		{"home", "", "", "~/config", configDir},
+		{"default", "", "", "", defaultDir},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			previous := dataDirFlag
+			dataDirFlag = tc.Flag
+			t.Cleanup(func() { dataDirFlag = previous })
+			t.Setenv("ARC_DATA_DIR", tc.Env)
+			writeConfig(t, tc.Config)
+
+			dir, err := findDataDir()
+			if tc.Name == "default" && defaultErr != nil {
+				if err == nil {
+					t.Errorf("got %s, want the error of the platform default: %v", dir, defaultErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if dir != tc.Want {
+				t.Errorf("got %s, want %s", dir, tc.Want)
+			}
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		t.Setenv("ARC_DATA_DIR", filepath.Join(home, "missing"))
+		if dir, err := findDataDir(); err == nil {
+			t.Errorf("got %s, want an error for a missing dir", dir)
+		}
+	})
+}
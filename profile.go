@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cli/go-gh/v2/pkg/tableprinter"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// profileFlag holds the global --profile flag, matched against the directory
+// or the display name of a profile, or "all".
+var profileFlag string
+
+type Profile struct {
+	Dir      string `json:"dir"`
+	Name     string `json:"name"`
+	UserName string `json:"userName,omitempty"`
+	LastUsed bool   `json:"lastUsed"`
+}
+
+func (p Profile) Path(name string) (string, error) {
+	return dataPath("User Data", p.Dir, name)
+}
+
+// loadProfiles lists the profiles registered in Local State, falling back to
+// the default profile when the file is missing.
+func loadProfiles() ([]Profile, error) {
+	path, err := dataPath("User Data", "Local State")
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []Profile{{Dir: "Default", Name: "Default", LastUsed: true}}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read local state: %w", err)
+	}
+
+	var localState struct {
+		Profile struct {
+			InfoCache map[string]struct {
+				Name     string `json:"name"`
+				UserName string `json:"user_name"`
+			} `json:"info_cache"`
+			LastUsed      string   `json:"last_used"`
+			ProfilesOrder []string `json:"profiles_order"`
+		} `json:"profile"`
+	}
+	if err := json.Unmarshal(content, &localState); err != nil {
+		return nil, fmt.Errorf("failed to parse local state: %w", err)
+	}
+
+	// profiles missing from profiles_order are listed last
+	ordered := make(map[string]bool)
+	for _, dir := range localState.Profile.ProfilesOrder {
+		ordered[dir] = true
+	}
+
+	var rest []string
+	for dir := range localState.Profile.InfoCache {
+		if !ordered[dir] {
+			rest = append(rest, dir)
+		}
+	}
+	sort.Strings(rest)
+
+	var profiles []Profile
+	for _, dir := range append(localState.Profile.ProfilesOrder, rest...) {
+		info, ok := localState.Profile.InfoCache[dir]
+		if !ok {
+			continue
+		}
+
+		profiles = append(profiles, Profile{
+			Dir:      dir,
+			Name:     info.Name,
+			UserName: info.UserName,
+			LastUsed: dir == localState.Profile.LastUsed,
+		})
+	}
+
+	return profiles, nil
+}
+
+// selectedProfiles resolves the --profile flag, defaulting to the default
+// profile.
+func selectedProfiles() ([]Profile, error) {
+	profiles, err := loadProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	if profileFlag == "all" {
+		return profiles, nil
+	}
+
+	name := profileFlag
+	if name == "" {
+		name = "Default"
+	}
+
+	for _, profile := range profiles {
+		if profile.Dir == name || strings.EqualFold(profile.Name, name) {
+			return []Profile{profile}, nil
+		}
+	}
+
+	if name == "Default" {
+		return []Profile{{Dir: "Default", Name: "Default"}}, nil
+	}
+
+	return nil, fmt.Errorf("profile not found: %s", name)
+}
+
+// selectedProfile resolves the --profile flag for commands reading a single
+// profile.
+func selectedProfile() (Profile, error) {
+	profiles, err := selectedProfiles()
+	if err != nil {
+		return Profile{}, err
+	}
+
+	if len(profiles) != 1 {
+		return Profile{}, fmt.Errorf("this command reads a single profile, use --profile to pick one")
+	}
+
+	return profiles[0], nil
+}
+
+func NewCmdProfile() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Manage profiles",
+	}
+
+	cmd.AddCommand(NewCmdProfileList())
+	return cmd
+}
+
+func NewCmdProfileList() *cobra.Command {
+	var flags struct {
+		Json bool
+	}
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List profiles",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profiles, err := loadProfiles()
+			if err != nil {
+				return err
+			}
+
+			if flags.Json {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				encoder.SetEscapeHTML(false)
+				return encoder.Encode(profiles)
+			}
+
+			var printer tableprinter.TablePrinter
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				printer = tableprinter.New(os.Stdout, false, 0)
+			} else {
+				w, _, err := term.GetSize(int(os.Stdout.Fd()))
+				if err != nil {
+					return err
+				}
+
+				printer = tableprinter.New(os.Stdout, true, w)
+			}
+
+			printer.AddHeader([]string{"Dir", "Name", "User", "LastUsed"})
+			for _, profile := range profiles {
+				printer.AddField(profile.Dir)
+				printer.AddField(profile.Name)
+				printer.AddField(profile.UserName)
+				printer.AddField(fmt.Sprintf("%t", profile.LastUsed))
+				printer.EndRow()
+			}
+
+			return printer.Render()
+		},
+	}
+
+	cmd.Flags().BoolVar(&flags.Json, "json", false, "output as json")
+	return cmd
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// useProfileFlag sets the --profile flag for the duration of a test.
+func useProfileFlag(t *testing.T, value string) {
+	t.Helper()
+
+	previous := profileFlag
+	profileFlag = value
+	t.Cleanup(func() { profileFlag = previous })
+}
+
+func writeLocalState(t *testing.T, dir string, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Join(dir, "User Data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "User Data", "Local State"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelectedProfiles(t *testing.T) {
+	dir := t.TempDir()
+	useDataDir(t, dir)
+
+	// without Local State, only the default profile is known
+	profiles, err := loadProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(profiles) != 1 || profiles[0].Dir != "Default" {
+		t.Errorf("got %+v, want the default profile", profiles)
+	}
+
+	// Profile 3 is gone from the info cache and Profile 1 is missing from the
+	// order
+	writeLocalState(t, dir, `{
+		"profile": {
+			"last_used": "Profile 2",
+			"profiles_order": ["Default", "Profile 2", "Profile 3"],
+			"info_cache": {
+				"Default": {"name": "Personal", "user_name": "me@example.com"},
+				"Profile 1": {"name": "Work"},
+				"Profile 2": {"name": "Side Project"}
+			}
+		}
+	}`)
+
+	profiles, err = loadProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Profile{
+		{Dir: "Default", Name: "Personal", UserName: "me@example.com"},
+		{Dir: "Profile 2", Name: "Side Project", LastUsed: true},
+		{Dir: "Profile 1", Name: "Work"},
+	}
+	if !slices.Equal(profiles, want) {
+		t.Errorf("got %+v, want %+v", profiles, want)
+	}
+
+	for _, tc := range []struct {
+		Flag string
+		Want []string
+	}{
+		{"", []string{"Default"}},
+		{"Profile 1", []string{"Profile 1"}},
+		{"side project", []string{"Profile 2"}},
+		{"all", []string{"Default", "Profile 2", "Profile 1"}},
+		{"Profile 3", nil},
+	} {
+		t.Run(tc.Flag, func(t *testing.T) {
+			useProfileFlag(t, tc.Flag)
+
+			profiles, err := selectedProfiles()
+			if tc.Want == nil {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", profiles)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var dirs []string
+			for _, profile := range profiles {
+				dirs = append(dirs, profile.Dir)
+			}
+
+			if !slices.Equal(dirs, tc.Want) {
+				t.Errorf("got %v, want %v", dirs, tc.Want)
+			}
+
+			if _, err := selectedProfile(); (err != nil) != (len(tc.Want) > 1) {
+				t.Errorf("selectedProfile() error = %v with %d profiles", err, len(tc.Want))
+			}
+		})
+	}
+}
+
+func TestLoadSidebarProfile(t *testing.T) {
+	dir := t.TempDir()
+	useDataDir(t, dir)
+
+	content, err := os.ReadFile("sidebar/testdata/StorableSidebar.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "StorableSidebar.json"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	writeLocalState(t, dir, `{"profile": {"info_cache": {"Default": {"name": "Personal"}, "Profile 1": {"name": "Work"}}}}`)
+
+	// spaces are only narrowed down when a profile is asked for
+	for _, tc := range []struct {
+		Flag   string
+		Spaces []string
+	}{
+		{"", []string{"Research", "Work"}},
+		{"all", []string{"Research", "Work"}},
+		{"Default", []string{"Research"}},
+		{"work", []string{"Work"}},
+	} {
+		t.Run(tc.Flag, func(t *testing.T) {
+			useProfileFlag(t, tc.Flag)
+
+			s, err := loadSidebar()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var titles []string
+			for _, space := range s.Spaces() {
+				titles = append(titles, space.Title)
+			}
+
+			if !slices.Equal(titles, tc.Spaces) {
+				t.Errorf("got %v, want %v", titles, tc.Spaces)
+			}
+		})
+	}
+}
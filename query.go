@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	sb "github.com/huandu/go-sqlbuilder"
+)
+
+const historyQueryHelp = `Queries are made of space separated terms, all of which must match:
+
+  word, "some phrase"   url or title contains the text
+  site:github.com       url is on this domain or one of its subdomains
+  title:"release notes" title contains the text
+  url:/issues/          url contains the text
+  after:2024-05-01      visited after this date
+  before:yesterday      visited before this date
+  on:"last monday"      visited on this day
+  transition:typed      visited with this transition type
+  visits>3              visit count comparison (>, >=, <, <=, =)
+  typed, typed>2        typed in the address bar, at least once or n times
+
+Other words followed by a colon, such as localhost:3000, are searched as
+text. Prefix a term with - to exclude matches, for example -site:google.com.`
+
+var queryFieldPattern = regexp.MustCompile(`^([a-zA-Z_]+)(:|>=|<=|>|<|=)`)
+
+// queryFields are the fields of terms. Other words followed by an operator,
+// such as localhost:3000 or about:blank, are searched as text.
+var queryFields = map[string]bool{
+	"site": true, "domain": true, "title": true, "url": true, "after": true,
+	"before": true, "on": true, "transition": true, "visits": true, "typed": true,
+}
+
+type queryTerm struct {
+	Column int
+	Negate bool
+	Field  string
+	Op     string
+	Value  string
+}
+
+type queryError struct {
+	Input  string
+	Column int
+	Msg    string
+}
+
+func (e *queryError) Error() string {
+	return fmt.Sprintf("invalid query at column %d: %s\n  %s\n  %s^", e.Column, e.Msg, e.Input, strings.Repeat(" ", e.Column-1))
+}
+
+// parseHistoryQuery splits a query into terms, validating fields and
+// operators.
+func parseHistoryQuery(input string) ([]queryTerm, error) {
+	column := func(offset int) int {
+		return utf8.RuneCountInString(input[:offset]) + 1
+	}
+
+	fail := func(offset int, format string, args ...any) error {
+		return &queryError{Input: input, Column: column(offset), Msg: fmt.Sprintf(format, args...)}
+	}
+
+	var terms []queryTerm
+	i := 0
+	for i < len(input) {
+		r, size := utf8.DecodeRuneInString(input[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+
+		// a token runs until the next space outside of quotes
+		start := i
+		for i < len(input) {
+			r, size := utf8.DecodeRuneInString(input[i:])
+			if unicode.IsSpace(r) {
+				break
+			}
+
+			if r == '"' {
+				end := strings.IndexByte(input[i+1:], '"')
+				if end < 0 {
+					return nil, fail(i, "unterminated quote")
+				}
+				i += end + 2
+				continue
+			}
+
+			i += size
+		}
+
+		token := input[start:i]
+		term := queryTerm{Column: column(start)}
+		offset := start
+
+		if len(token) > 1 && token[0] == '-' {
+			term.Negate = true
+			token = token[1:]
+			offset++
+		}
+
+		match := queryFieldPattern.FindStringSubmatch(token)
+		if match == nil || !queryFields[strings.ToLower(match[1])] || (match[2] == ":" && strings.HasPrefix(token[len(match[0]):], "//")) {
+			if strings.EqualFold(token, "typed") {
+				term.Field = "typed"
+				term.Op = ">"
+				term.Value = "0"
+			} else {
+				term.Value = unquote(token)
+			}
+
+			terms = append(terms, term)
+			continue
+		}
+
+		term.Field = strings.ToLower(match[1])
+		term.Op = match[2]
+		term.Value = unquote(token[len(match[0]):])
+
+		switch term.Field {
+		case "site", "domain", "title", "url", "after", "before", "on", "transition":
+			if term.Op != ":" {
+				return nil, fail(offset+len(match[1]), "%s does not support %s, use %s:", term.Field, term.Op, term.Field)
+			}
+		case "visits", "typed":
+			if term.Op == ":" {
+				term.Op = "="
+			}
+			if _, err := strconv.Atoi(term.Value); err != nil {
+				return nil, fail(offset+len(match[0]), "%s expects a number", term.Field)
+			}
+		}
+
+		if term.Value == "" {
+			return nil, fail(offset+len(match[0]), "missing value for %s", term.Field)
+		}
+
+		if term.Field == "transition" {
+			if _, err := parseTransitionType(term.Value); err != nil {
+				return nil, fail(offset+len(match[0]), "%s", err)
+			}
+		}
+
+		if term.Field == "after" || term.Field == "before" || term.Field == "on" {
+			if _, err := parseTime(term.Value); err != nil {
+				return nil, fail(offset+len(match[0]), "%s", err)
+			}
+		}
+
+		terms = append(terms, term)
+	}
+
+	return terms, nil
+}
+
+func unquote(value string) string {
+	return strings.ReplaceAll(value, `"`, "")
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likeCondition matches a column against a LIKE pattern made of a prefix, a
+// literal value in which wildcards are escaped and a suffix.
+func likeCondition(query *sb.SelectBuilder, column string, prefix string, value string, suffix string) string {
+	return fmt.Sprintf(`%s LIKE %s ESCAPE '\'`, column, query.Var(prefix+likeEscaper.Replace(value)+suffix))
+}
+
+// isVisitTerm reports whether a term filters visits rather than urls.
+func isVisitTerm(term queryTerm) bool {
+	switch term.Field {
+	case "after", "before", "on", "transition":
+		return true
+	}
+	return false
+}
+
+// visitCondition turns a date or transition term into a condition on the
+// visits table, ignoring its negation.
+func visitCondition(term queryTerm, query *sb.SelectBuilder) (string, error) {
+	if term.Field == "transition" {
+		core, err := parseTransitionType(term.Value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(visits.transition & %d) = %s", transitionCoreMask, query.Var(core)), nil
+	}
+
+	t, err := parseTime(term.Value)
+	if err != nil {
+		return "", err
+	}
+
+	switch term.Field {
+	case "after":
+		return query.GreaterEqualThan("visits.visit_time", toChromeTime(t)), nil
+	case "before":
+		return query.LessThan("visits.visit_time", toChromeTime(t)), nil
+	default:
+		day := startOfDay(t.Local())
+		return query.And(
+			query.GreaterEqualThan("visits.visit_time", toChromeTime(day)),
+			query.LessThan("visits.visit_time", toChromeTime(day.AddDate(0, 0, 1))),
+		), nil
+	}
+}
+
+// compileHistoryQuery turns terms into conditions on the urls table, using
+// the visits table for date and transition filters.
+func compileHistoryQuery(terms []queryTerm, query *sb.SelectBuilder) ([]string, error) {
+	visitExists := func(condition string) string {
+		return fmt.Sprintf("EXISTS (SELECT 1 FROM visits WHERE visits.url = urls.id AND %s)", condition)
+	}
+
+	var conditions []string
+	for _, term := range terms {
+		var condition string
+		switch term.Field {
+		case "":
+			condition = query.Or(
+				likeCondition(query, "urls.url", "%", term.Value, "%"),
+				likeCondition(query, "urls.title", "%", term.Value, "%"),
+			)
+		case "site", "domain":
+			site := strings.ToLower(term.Value)
+			condition = query.Or(
+				likeCondition(query, "urls.url", "%://", site, "/%"),
+				likeCondition(query, "urls.url", "%://", site, ":%"),
+				likeCondition(query, "urls.url", "%://%.", site, "/%"),
+				likeCondition(query, "urls.url", "%://%.", site, ":%"),
+			)
+		case "title":
+			condition = likeCondition(query, "urls.title", "%", term.Value, "%")
+		case "url":
+			condition = likeCondition(query, "urls.url", "%", term.Value, "%")
+		case "after", "before", "on", "transition":
+			visit, err := visitCondition(term, query)
+			if err != nil {
+				return nil, err
+			}
+			condition = visitExists(visit)
+		case "visits", "typed":
+			column := "urls.visit_count"
+			if term.Field == "typed" {
+				column = "urls.typed_count"
+			}
+
+			n, err := strconv.Atoi(term.Value)
+			if err != nil {
+				return nil, err
+			}
+			condition = fmt.Sprintf("%s %s %s", column, term.Op, query.Var(n))
+		}
+
+		if term.Negate {
+			condition = fmt.Sprintf("NOT (%s)", condition)
+		}
+
+		conditions = append(conditions, condition)
+	}
+
+	return conditions, nil
+}
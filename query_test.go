@@ -0,0 +1,165 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	sb "github.com/huandu/go-sqlbuilder"
+)
+
+func TestParseHistoryQuery(t *testing.T) {
+	for _, tt := range []struct {
+		input string
+		want  []queryTerm
+	}{
+		{"", nil},
+		{"arc cli", []queryTerm{
+			{Column: 1, Value: "arc"},
+			{Column: 5, Value: "cli"},
+		}},
+		{`"release notes" title:"arc cli"`, []queryTerm{
+			{Column: 1, Value: "release notes"},
+			{Column: 17, Field: "title", Op: ":", Value: "arc cli"},
+		}},
+		{"-site:google.com -ads", []queryTerm{
+			{Column: 1, Negate: true, Field: "site", Op: ":", Value: "google.com"},
+			{Column: 18, Negate: true, Value: "ads"},
+		}},
+		{"visits>3 visits>=10 typed<2 visits:4", []queryTerm{
+			{Column: 1, Field: "visits", Op: ">", Value: "3"},
+			{Column: 10, Field: "visits", Op: ">=", Value: "10"},
+			{Column: 21, Field: "typed", Op: "<", Value: "2"},
+			{Column: 29, Field: "visits", Op: "=", Value: "4"},
+		}},
+		{"typed -Typed", []queryTerm{
+			{Column: 1, Field: "typed", Op: ">", Value: "0"},
+			{Column: 7, Negate: true, Field: "typed", Op: ">", Value: "0"},
+		}},
+		{"https://github.com/pomdtr/arc http://x", []queryTerm{
+			{Column: 1, Value: "https://github.com/pomdtr/arc"},
+			{Column: 31, Value: "http://x"},
+		}},
+		{"transition:typed on:yesterday", []queryTerm{
+			{Column: 1, Field: "transition", Op: ":", Value: "typed"},
+			{Column: 18, Field: "on", Op: ":", Value: "yesterday"},
+		}},
+		{"-", []queryTerm{{Column: 1, Value: "-"}}},
+		// unknown fields are text
+		{"arc foo:bar -foo:bar", []queryTerm{
+			{Column: 1, Value: "arc"},
+			{Column: 5, Value: "foo:bar"},
+			{Column: 13, Negate: true, Value: "foo:bar"},
+		}},
+		{"localhost:3000 about:blank chrome:settings", []queryTerm{
+			{Column: 1, Value: "localhost:3000"},
+			{Column: 16, Value: "about:blank"},
+			{Column: 28, Value: "chrome:settings"},
+		}},
+	} {
+		got, err := parseHistoryQuery(tt.input)
+		if err != nil {
+			t.Errorf("%q: %s", tt.input, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseHistoryQueryErrors(t *testing.T) {
+	for _, tt := range []struct {
+		input  string
+		column int
+	}{
+		{`arc "release notes`, 5},
+		{"arc visits>x", 12},
+		{"arc -site:", 11},
+		{"visits>many", 8},
+		{"title>3", 6},
+		{"site:", 6},
+		{"transition:teleport", 12},
+		{"after:someday", 7},
+		// columns count runes, not bytes
+		{"été title>3", 10},
+	} {
+		_, err := parseHistoryQuery(tt.input)
+
+		var queryErr *queryError
+		if !errors.As(err, &queryErr) {
+			t.Errorf("%q: got %v, want a query error", tt.input, err)
+			continue
+		}
+
+		if queryErr.Column != tt.column {
+			t.Errorf("%q: error at column %d, want %d: %s", tt.input, queryErr.Column, tt.column, err)
+		}
+	}
+}
+
+func TestCompileHistoryQueryEscapesWildcards(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`CREATE TABLE urls (id INTEGER PRIMARY KEY, url TEXT, title TEXT);
+		CREATE TABLE visits (id INTEGER PRIMARY KEY, url INTEGER, visit_time INTEGER, transition INTEGER);
+		INSERT INTO urls (url, title) VALUES
+			('https://my_site.com/', 'underscore'),
+			('https://myxsite.com/', 'wildcard match'),
+			('https://docs.my_site.com:8080/a', 'subdomain'),
+			('https://example.com/100%', 'percent'),
+			('https://example.com/1000', 'digits')`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"site:my_site.com", []string{"subdomain", "underscore"}},
+		{"100%", []string{"percent"}},
+		{"url:my_", []string{"subdomain", "underscore"}},
+		{"-site:my_site.com", []string{"digits", "percent", "wildcard match"}},
+	} {
+		terms, err := parseHistoryQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		query := sb.NewSelectBuilder()
+		query.Select("urls.title").From("urls")
+		conditions, err := compileHistoryQuery(terms, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query.Where(conditions...)
+
+		statement, args := query.Build()
+		rows, err := db.Query(statement, args...)
+		if err != nil {
+			t.Fatalf("%q: %s", tt.query, err)
+		}
+
+		var got []string
+		for rows.Next() {
+			var title string
+			if err := rows.Scan(&title); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, title)
+		}
+		rows.Close()
+
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/cli/go-gh/v2/pkg/tableprinter"
+	sb "github.com/huandu/go-sqlbuilder"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+type SearchTerm struct {
+	Term       string    `json:"term"`
+	URL        string    `json:"url,omitempty"`
+	Count      int       `json:"count"`
+	SearchedAt time.Time `json:"searchedAt"`
+	KeywordID  int64     `json:"keywordId"`
+	Engine     string    `json:"engine,omitempty"`
+}
+
+// searchEngines maps keyword ids to engine names, read from the keywords
+// table of the Web Data database.
+func searchEngines() (map[int64]string, error) {
+	profile, err := selectedProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := profile.Path("Web Data")
+	if err != nil {
+		return nil, err
+	}
+
+	db, cleanup, err := openSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	rows, err := db.Query("SELECT id, short_name FROM keywords")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	defer rows.Close()
+
+	engines := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		engines[id] = name
+	}
+
+	return engines, rows.Err()
+}
+
+func NewCmdHistorySearches() *cobra.Command {
+	var flags struct {
+		timeRange
+		query  string
+		group  bool
+		engine bool
+		limit  int
+		json   bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "searches",
+		Short: "List terms typed into search engines",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var engines map[int64]string
+			if flags.engine {
+				var err error
+				if engines, err = searchEngines(); err != nil {
+					return err
+				}
+			}
+
+			db, cleanup, err := openHistory()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			query := sb.NewSelectBuilder()
+			query.From("keyword_search_terms k")
+			query.Join("urls u", "u.id = k.url_id")
+			if flags.group {
+				query.Select("MIN(k.term)", "''", "SUM(u.visit_count)", "MAX(u.last_visit_time)", "k.keyword_id")
+				query.GroupBy("k.normalized_term", "k.keyword_id")
+				query.OrderBy("MAX(u.last_visit_time) DESC")
+			} else {
+				query.Select("k.term", "u.url", "u.visit_count", "u.last_visit_time", "k.keyword_id")
+				query.OrderBy("u.last_visit_time DESC")
+			}
+
+			if flags.limit > 0 {
+				query.Limit(flags.limit)
+			}
+
+			if flags.query != "" {
+				query.Where(likeCondition(query, "k.term", "%", flags.query, "%"))
+			}
+
+			if err := flags.timeRange.where(query, "u.last_visit_time"); err != nil {
+				return err
+			}
+
+			sql, args := query.Build()
+			rows, err := db.Query(sql, args...)
+			if err != nil {
+				return fmt.Errorf("failed to query: %w", err)
+			}
+			defer rows.Close()
+
+			var terms []SearchTerm
+			for rows.Next() {
+				var term SearchTerm
+				var searchTime int64
+				if err := rows.Scan(&term.Term, &term.URL, &term.Count, &searchTime, &term.KeywordID); err != nil {
+					return fmt.Errorf("failed to scan: %w", err)
+				}
+
+				term.SearchedAt = chromeTime(searchTime)
+				term.Engine = engines[term.KeywordID]
+				terms = append(terms, term)
+			}
+
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("failed to query: %w", err)
+			}
+
+			if flags.json {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				encoder.SetEscapeHTML(false)
+				return encoder.Encode(terms)
+			}
+
+			var printer tableprinter.TablePrinter
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				printer = tableprinter.New(os.Stdout, false, 0)
+			} else {
+				w, _, err := term.GetSize(int(os.Stdout.Fd()))
+				if err != nil {
+					return err
+				}
+
+				printer = tableprinter.New(os.Stdout, true, w)
+			}
+
+			header := []string{"Term", "Count", "SearchedAt"}
+			if flags.engine {
+				header = append(header, "Engine")
+			}
+			if !flags.group {
+				header = append(header, "URL")
+			}
+
+			printer.AddHeader(header)
+			for _, term := range terms {
+				printer.AddField(term.Term)
+				printer.AddField(strconv.Itoa(term.Count))
+				printer.AddField(term.SearchedAt.Local().Format(time.DateTime))
+				if flags.engine {
+					printer.AddField(term.Engine)
+				}
+				if !flags.group {
+					printer.AddField(term.URL)
+				}
+				printer.EndRow()
+			}
+
+			return printer.Render()
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.query, "query", "q", "", "only show terms containing this string")
+	cmd.Flags().BoolVar(&flags.group, "group", false, "group searches by term")
+	cmd.Flags().BoolVar(&flags.engine, "engine", false, "show the search engine of each term")
+	cmd.Flags().IntVarP(&flags.limit, "limit", "l", 100, "limit")
+	cmd.Flags().BoolVar(&flags.json, "json", false, "output as json")
+	flags.timeRange.register(cmd)
+
+	return cmd
+}
@@ -0,0 +1,573 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf16"
+
+	"github.com/cli/go-gh/v2/pkg/tableprinter"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// Session files are SNSS command streams: a header made of the magic bytes
+// and a version, followed by commands made of a little endian uint16 size,
+// a command id and size-1 bytes of payload. Replaying the commands in order
+// rebuilds the state of the windows and tabs.
+var snssMagic = []byte("SNSS")
+
+type snssCommand struct {
+	ID      uint8
+	Payload []byte
+}
+
+func readSNSS(r io.Reader) ([]snssCommand, error) {
+	reader := bufio.NewReader(r)
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("failed to read session header: %w", err)
+	}
+
+	if !bytes.Equal(header[:4], snssMagic) {
+		return nil, errors.New("not a session file")
+	}
+
+	// version 2 marks encrypted files, 3 files with an initial state marker
+	version := binary.LittleEndian.Uint32(header[4:])
+	if version != 1 && version != 3 {
+		return nil, fmt.Errorf("unsupported session file version: %d", version)
+	}
+
+	var commands []snssCommand
+	for {
+		var size uint16
+		if err := binary.Read(reader, binary.LittleEndian, &size); err != nil {
+			if errors.Is(err, io.EOF) {
+				return commands, nil
+			}
+
+			return nil, fmt.Errorf("failed to read session command: %w", err)
+		}
+
+		if size == 0 {
+			continue
+		}
+
+		command := make([]byte, size)
+		if _, err := io.ReadFull(reader, command); err != nil {
+			// the last command is cut short when Arc is writing the file
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return commands, nil
+			}
+
+			return nil, fmt.Errorf("failed to read session command: %w", err)
+		}
+
+		commands = append(commands, snssCommand{ID: command[0], Payload: command[1:]})
+	}
+}
+
+// pickleReader reads the fields of a serialized base::Pickle, which starts
+// with the size of its payload and aligns every field to 4 bytes.
+type pickleReader struct {
+	data []byte
+	err  error
+}
+
+func newPickleReader(payload []byte) *pickleReader {
+	if len(payload) < 4 {
+		return &pickleReader{err: errors.New("pickle too short")}
+	}
+
+	size := int(binary.LittleEndian.Uint32(payload))
+	if size > len(payload)-4 {
+		size = len(payload) - 4
+	}
+
+	return &pickleReader{data: payload[4 : 4+size]}
+}
+
+func (p *pickleReader) next(n int) []byte {
+	if p.err != nil {
+		return nil
+	}
+
+	if n < 0 || n > len(p.data) {
+		p.err = errors.New("pickle field out of bounds")
+		return nil
+	}
+
+	field := p.data[:n]
+	p.data = p.data[min((n+3)&^3, len(p.data)):]
+	return field
+}
+
+func (p *pickleReader) ReadInt32() int32 {
+	field := p.next(4)
+	if field == nil {
+		return 0
+	}
+
+	return int32(binary.LittleEndian.Uint32(field))
+}
+
+func (p *pickleReader) ReadString() string {
+	return string(p.next(int(p.ReadInt32())))
+}
+
+func (p *pickleReader) ReadString16() string {
+	field := p.next(2 * int(p.ReadInt32()))
+	units := make([]uint16, len(field)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(field[2*i:])
+	}
+
+	return string(utf16.Decode(units))
+}
+
+// Commands of Session files, as written by the session service.
+const (
+	sessionCommandSetTabWindow                     = 0
+	sessionCommandTabNavigationPathPrunedFromBack  = 5
+	sessionCommandUpdateTabNavigation              = 6
+	sessionCommandSetSelectedNavigationIndex       = 7
+	sessionCommandTabNavigationPathPrunedFromFront = 11
+	sessionCommandTabClosed                        = 16
+	sessionCommandWindowClosed                     = 17
+	sessionCommandTabNavigationPathPruned          = 24
+)
+
+// Commands of Tabs files, as written by the tab restore service.
+const (
+	tabRestoreCommandUpdateTabNavigation     = 1
+	tabRestoreCommandRestoredEntry           = 2
+	tabRestoreCommandSelectedNavigationInTab = 4
+)
+
+type Navigation struct {
+	Index int    `json:"index"`
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// parseNavigation reads the tab id and navigation of an update tab
+// navigation command, shared by both kinds of files.
+func parseNavigation(payload []byte) (int32, Navigation, error) {
+	pickle := newPickleReader(payload)
+	tabID := pickle.ReadInt32()
+	navigation := Navigation{
+		Index: int(pickle.ReadInt32()),
+		URL:   pickle.ReadString(),
+		Title: pickle.ReadString16(),
+	}
+
+	if pickle.err != nil {
+		return 0, Navigation{}, fmt.Errorf("failed to parse navigation: %w", pickle.err)
+	}
+
+	return tabID, navigation, nil
+}
+
+// payloadInt32s reads the leading int32 fields of a fixed size payload.
+func payloadInt32s(payload []byte, n int) ([]int32, bool) {
+	if len(payload) < 4*n {
+		return nil, false
+	}
+
+	values := make([]int32, n)
+	for i := range values {
+		values[i] = int32(binary.LittleEndian.Uint32(payload[4*i:]))
+	}
+
+	return values, true
+}
+
+// payloadTime reads the int64 timestamp following an id in closed and
+// selected navigation payloads, padded to 8 bytes.
+func payloadTime(payload []byte, offset int) time.Time {
+	if len(payload) < offset+8 {
+		return time.Time{}
+	}
+
+	return chromeTime(int64(binary.LittleEndian.Uint64(payload[offset:])))
+}
+
+type ClosedTab struct {
+	ClosedAt    time.Time    `json:"closedAt"`
+	URL         string       `json:"url"`
+	Title       string       `json:"title"`
+	Navigations []Navigation `json:"navigations"`
+
+	id       int32
+	window   int32
+	selected int
+}
+
+// setNavigation adds or replaces the navigation at its index.
+func (t *ClosedTab) setNavigation(navigation Navigation) {
+	for i, existing := range t.Navigations {
+		if existing.Index == navigation.Index {
+			t.Navigations[i] = navigation
+			return
+		}
+	}
+
+	t.Navigations = append(t.Navigations, navigation)
+}
+
+// prune removes count navigations starting at index, shifting the following
+// ones back.
+func (t *ClosedTab) prune(index int, count int) {
+	var kept []Navigation
+	for _, navigation := range t.Navigations {
+		switch {
+		case navigation.Index < index:
+			kept = append(kept, navigation)
+		case navigation.Index >= index+count:
+			navigation.Index -= count
+			kept = append(kept, navigation)
+		}
+	}
+
+	t.Navigations = kept
+}
+
+// finish sorts the navigation stack and copies the selected navigation.
+func (t *ClosedTab) finish() {
+	sort.Slice(t.Navigations, func(i, j int) bool {
+		return t.Navigations[i].Index < t.Navigations[j].Index
+	})
+
+	for _, navigation := range t.Navigations {
+		if navigation.Index == t.selected {
+			t.URL = navigation.URL
+			t.Title = navigation.Title
+		}
+	}
+
+	if t.URL == "" && len(t.Navigations) > 0 {
+		last := t.Navigations[len(t.Navigations)-1]
+		t.URL = last.URL
+		t.Title = last.Title
+	}
+}
+
+// replaySession returns the tabs closed during a session, on their own or
+// along with their window. Malformed commands are skipped, as Chromium does.
+func replaySession(commands []snssCommand) []ClosedTab {
+	tabs := make(map[int32]*ClosedTab)
+	tab := func(id int32) *ClosedTab {
+		if _, ok := tabs[id]; !ok {
+			tabs[id] = &ClosedTab{id: id}
+		}
+		return tabs[id]
+	}
+
+	closedWindows := make(map[int32]time.Time)
+	for _, command := range commands {
+		switch command.ID {
+		case sessionCommandSetTabWindow:
+			if values, ok := payloadInt32s(command.Payload, 2); ok {
+				tab(values[1]).window = values[0]
+			}
+		case sessionCommandUpdateTabNavigation:
+			if tabID, navigation, err := parseNavigation(command.Payload); err == nil {
+				tab(tabID).setNavigation(navigation)
+			}
+		case sessionCommandSetSelectedNavigationIndex:
+			if values, ok := payloadInt32s(command.Payload, 2); ok {
+				tab(values[0]).selected = int(values[1])
+			}
+		case sessionCommandTabNavigationPathPrunedFromBack:
+			if values, ok := payloadInt32s(command.Payload, 2); ok {
+				tab(values[0]).prune(int(values[1]), 1<<30)
+			}
+		case sessionCommandTabNavigationPathPrunedFromFront:
+			if values, ok := payloadInt32s(command.Payload, 2); ok {
+				tab(values[0]).prune(0, int(values[1]))
+			}
+		case sessionCommandTabNavigationPathPruned:
+			if values, ok := payloadInt32s(command.Payload, 3); ok {
+				tab(values[0]).prune(int(values[1]), int(values[2]))
+			}
+		case sessionCommandTabClosed:
+			if values, ok := payloadInt32s(command.Payload, 1); ok {
+				tab(values[0]).ClosedAt = payloadTime(command.Payload, 8)
+			}
+		case sessionCommandWindowClosed:
+			if values, ok := payloadInt32s(command.Payload, 1); ok {
+				closedWindows[values[0]] = payloadTime(command.Payload, 8)
+			}
+		}
+	}
+
+	var closed []ClosedTab
+	for _, tab := range tabs {
+		if closedAt, ok := closedWindows[tab.window]; ok && tab.ClosedAt.IsZero() {
+			tab.ClosedAt = closedAt
+		}
+
+		if tab.ClosedAt.IsZero() || len(tab.Navigations) == 0 {
+			continue
+		}
+
+		tab.finish()
+		closed = append(closed, *tab)
+	}
+
+	return closed
+}
+
+// replayTabRestore returns the closed tabs kept by the tab restore service,
+// which backs the recently closed menu. Every tab starts with its selected
+// navigation, followed by the navigations of its stack.
+func replayTabRestore(commands []snssCommand) []ClosedTab {
+	var tabs []*ClosedTab
+	restored := make(map[int32]bool)
+	for _, command := range commands {
+		switch command.ID {
+		case tabRestoreCommandSelectedNavigationInTab:
+			if values, ok := payloadInt32s(command.Payload, 2); ok {
+				tabs = append(tabs, &ClosedTab{
+					id:       values[0],
+					selected: int(values[1]),
+					ClosedAt: payloadTime(command.Payload, 8),
+				})
+			}
+		case tabRestoreCommandUpdateTabNavigation:
+			tabID, navigation, err := parseNavigation(command.Payload)
+			if err == nil && len(tabs) > 0 && tabs[len(tabs)-1].id == tabID {
+				tabs[len(tabs)-1].setNavigation(navigation)
+			}
+		case tabRestoreCommandRestoredEntry:
+			if values, ok := payloadInt32s(command.Payload, 1); ok {
+				restored[values[0]] = true
+			}
+		}
+	}
+
+	var closed []ClosedTab
+	for _, tab := range tabs {
+		if restored[tab.id] || len(tab.Navigations) == 0 {
+			continue
+		}
+
+		tab.finish()
+		closed = append(closed, *tab)
+	}
+
+	return closed
+}
+
+// latestSessionFile returns the most recent file of the Sessions dir with
+// the given prefix, file names ending with their creation time.
+func latestSessionFile(dir string, prefix string) (string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, prefix+"_*"))
+	if err != nil {
+		return "", err
+	}
+
+	if len(files) == 0 {
+		return "", nil
+	}
+
+	sort.Strings(files)
+	return files[len(files)-1], nil
+}
+
+func replaySessionFile(path string, replay func([]snssCommand) []ClosedTab) ([]ClosedTab, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session file: %w", err)
+	}
+	defer f.Close()
+
+	commands, err := readSNSS(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+
+	return replay(commands), nil
+}
+
+// loadClosedTabs lists recently closed tabs, most recent first. Tabs are read
+// from the tab restore service and from the current session, which records
+// tabs closed since the restore file was last written. A tab found in both is
+// listed once.
+func loadClosedTabs() ([]ClosedTab, error) {
+	profile, err := selectedProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := profile.Path("Sessions")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to open sessions dir: %w", err)
+	}
+
+	var tabs []ClosedTab
+	for _, source := range []struct {
+		prefix string
+		replay func([]snssCommand) []ClosedTab
+	}{
+		{"Tabs", replayTabRestore},
+		{"Session", replaySession},
+	} {
+		path, err := latestSessionFile(dir, source.prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		if path == "" {
+			continue
+		}
+
+		closed, err := replaySessionFile(path, source.replay)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tab := range closed {
+			duplicate := false
+			for _, existing := range tabs {
+				if existing.URL == tab.URL && existing.ClosedAt.Sub(tab.ClosedAt).Abs() < 5*time.Second {
+					duplicate = true
+					break
+				}
+			}
+
+			if !duplicate {
+				tabs = append(tabs, tab)
+			}
+		}
+	}
+
+	sort.SliceStable(tabs, func(i, j int) bool {
+		return tabs[i].ClosedAt.After(tabs[j].ClosedAt)
+	})
+
+	return tabs, nil
+}
+
+func NewCmdTabClosed() *cobra.Command {
+	var flags struct {
+		Limit int
+		Json  bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "closed",
+		Short: "List recently closed tabs",
+		Long: `List recently closed tabs.
+
+Tabs are read from the session files of the profile, most recently closed
+first, along with the pages of their back and forward history. Pass the
+number of a tab to arc tab reopen to restore it.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tabs, err := loadClosedTabs()
+			if err != nil {
+				return err
+			}
+
+			if flags.Limit > 0 && len(tabs) > flags.Limit {
+				tabs = tabs[:flags.Limit]
+			}
+
+			if flags.Json {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				encoder.SetEscapeHTML(false)
+				return encoder.Encode(tabs)
+			}
+
+			var printer tableprinter.TablePrinter
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				printer = tableprinter.New(os.Stdout, false, 0)
+			} else {
+				w, _, err := term.GetSize(int(os.Stdout.Fd()))
+				if err != nil {
+					return err
+				}
+
+				printer = tableprinter.New(os.Stdout, true, w)
+			}
+
+			printer.AddHeader([]string{"#", "ClosedAt", "Title", "URL", "Pages"})
+			for i, tab := range tabs {
+				printer.AddField(strconv.Itoa(i + 1))
+				printer.AddField(formatTime(tab.ClosedAt.Local()))
+				printer.AddField(tab.Title)
+				printer.AddField(tab.URL)
+				printer.AddField(strconv.Itoa(len(tab.Navigations)))
+				printer.EndRow()
+			}
+
+			return printer.Render()
+		},
+	}
+
+	cmd.Flags().IntVarP(&flags.Limit, "limit", "l", 25, "limit")
+	cmd.Flags().BoolVar(&flags.Json, "json", false, "output as json")
+	return cmd
+}
+
+func NewCmdTabReopen() *cobra.Command {
+	var flags struct {
+		Space int
+	}
+
+	cmd := &cobra.Command{
+		Use:   "reopen [n]",
+		Short: "Reopen a recently closed tab",
+		Long: `Reopen a recently closed tab.
+
+The tab is picked by its number in arc tab closed, defaulting to the most
+recently closed one. Only its current page is restored, not its back and
+forward history.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n := 1
+			if len(args) > 0 {
+				var err error
+				if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+					return fmt.Errorf("invalid tab number: %s", args[0])
+				}
+			}
+
+			tabs, err := loadClosedTabs()
+			if err != nil {
+				return err
+			}
+
+			if n > len(tabs) {
+				return fmt.Errorf("only %d closed tabs found", len(tabs))
+			}
+
+			if cmd.Flags().Changed("space") {
+				return createTabInSpace(tabs[n-1].URL, flags.Space)
+			}
+
+			return createTab(tabs[n-1].URL)
+		},
+	}
+
+	cmd.Flags().IntVar(&flags.Space, "space", 0, "space to reopen the tab in")
+	return cmd
+}
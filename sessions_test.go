@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// sessionTime is the time the samples of testdata were written at,
+// 2024-05-15 12:00 UTC.
+var sessionTime = time.Date(2024, 5, 15, 12, 0, 0, 0, time.UTC)
+
+func int32sCommand(id uint8, values ...int32) snssCommand {
+	payload := make([]byte, 4*len(values))
+	for i, value := range values {
+		binary.LittleEndian.PutUint32(payload[4*i:], uint32(value))
+	}
+
+	return snssCommand{ID: id, Payload: payload}
+}
+
+// timeCommand encodes the closed and selected navigation commands, an id and
+// an int32 padded to 8 bytes followed by a timestamp.
+func timeCommand(id uint8, target int32, value int32, t time.Time) snssCommand {
+	command := int32sCommand(id, target, value)
+	command.Payload = binary.LittleEndian.AppendUint64(command.Payload, uint64(toChromeTime(t)))
+	return command
+}
+
+func navigationCommand(id uint8, tab int32, index int32, url string, title string) snssCommand {
+	pad := func(b []byte) []byte {
+		return append(b, make([]byte, -len(b)&3)...)
+	}
+
+	body := binary.LittleEndian.AppendUint32(nil, uint32(tab))
+	body = binary.LittleEndian.AppendUint32(body, uint32(index))
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(url)))
+	body = pad(append(body, url...))
+
+	units := utf16.Encode([]rune(title))
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(units)))
+	for _, unit := range units {
+		body = binary.LittleEndian.AppendUint16(body, unit)
+	}
+	body = pad(body)
+
+	return snssCommand{ID: id, Payload: append(binary.LittleEndian.AppendUint32(nil, uint32(len(body))), body...)}
+}
+
+func sortClosedTabs(tabs []ClosedTab) {
+	sort.Slice(tabs, func(i, j int) bool {
+		return tabs[i].ClosedAt.After(tabs[j].ClosedAt)
+	})
+}
+
+func TestReadSNSS(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		data  string
+		count int
+		ok    bool
+	}{
+		{"empty", "SNSS\x01\x00\x00\x00", 0, true},
+		{"commands", "SNSS\x01\x00\x00\x00\x02\x00\x07a\x01\x00\x10", 2, true},
+		// empty commands are skipped
+		{"empty command", "SNSS\x03\x00\x00\x00\x00\x00\x01\x00\x10", 1, true},
+		{"truncated command", "SNSS\x01\x00\x00\x00\x01\x00\x10\x20\x00\x06abc", 1, true},
+		{"truncated size", "SNSS\x01\x00\x00\x00\x01\x00\x10\x20", 0, false},
+		{"short header", "SNSS\x01", 0, false},
+		{"bad magic", "SNSX\x01\x00\x00\x00", 0, false},
+		{"encrypted", "SNSS\x02\x00\x00\x00", 0, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			commands, err := readSNSS(bytes.NewReader([]byte(tt.data)))
+			if (err == nil) != tt.ok {
+				t.Fatalf("readSNSS() error = %v, want ok = %v", err, tt.ok)
+			}
+
+			if len(commands) != tt.count {
+				t.Errorf("got %d commands, want %d", len(commands), tt.count)
+			}
+		})
+	}
+}
+
+func TestClosedTabPrune(t *testing.T) {
+	indexes := func(tab ClosedTab) (result []string) {
+		for _, navigation := range tab.Navigations {
+			result = append(result, fmt.Sprintf("%s@%d", navigation.URL, navigation.Index))
+		}
+		return result
+	}
+
+	for _, tt := range []struct {
+		name  string
+		index int
+		count int
+		want  []string
+	}{
+		// the forward history is dropped when navigating after going back
+		{"from back", 2, 1 << 30, []string{"a@0", "b@1"}},
+		// the oldest navigations are dropped past the size of the stack
+		{"from front", 0, 2, []string{"c@0", "d@1", "e@2"}},
+		{"middle", 1, 2, []string{"a@0", "d@1", "e@2"}},
+		{"out of range", 7, 1, []string{"a@0", "b@1", "c@2", "d@3", "e@4"}},
+		{"everything", 0, 5, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var tab ClosedTab
+			for i, url := range []string{"a", "b", "c", "d", "e"} {
+				tab.setNavigation(Navigation{Index: i, URL: url})
+			}
+
+			tab.prune(tt.index, tt.count)
+			if got := indexes(tab); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("prune(%d, %d) = %v, want %v", tt.index, tt.count, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaySession(t *testing.T) {
+	at := func(seconds int) time.Time {
+		return sessionTime.Add(time.Duration(seconds) * time.Second).Local()
+	}
+
+	navigation := func(tab int32, index int32, url string) snssCommand {
+		return navigationCommand(sessionCommandUpdateTabNavigation, tab, index, url, url)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		commands []snssCommand
+		want     map[string]time.Time
+	}{
+		{"tab closed", []snssCommand{
+			int32sCommand(sessionCommandSetTabWindow, 1, 10),
+			navigation(10, 0, "a"),
+			timeCommand(sessionCommandTabClosed, 10, 0, at(1)),
+		}, map[string]time.Time{"a": at(1)}},
+		{"open tab", []snssCommand{
+			int32sCommand(sessionCommandSetTabWindow, 1, 10),
+			navigation(10, 0, "a"),
+		}, map[string]time.Time{}},
+		{"window closed", []snssCommand{
+			int32sCommand(sessionCommandSetTabWindow, 1, 10),
+			int32sCommand(sessionCommandSetTabWindow, 2, 11),
+			navigation(10, 0, "a"),
+			navigation(11, 0, "b"),
+			timeCommand(sessionCommandWindowClosed, 2, 0, at(2)),
+		}, map[string]time.Time{"b": at(2)}},
+		// tabs closed before their window keep their own time
+		{"tab closed before its window", []snssCommand{
+			int32sCommand(sessionCommandSetTabWindow, 2, 10),
+			navigation(10, 0, "a"),
+			timeCommand(sessionCommandTabClosed, 10, 0, at(1)),
+			timeCommand(sessionCommandWindowClosed, 2, 0, at(2)),
+		}, map[string]time.Time{"a": at(1)}},
+		{"selected navigation", []snssCommand{
+			navigation(10, 0, "a"),
+			navigation(10, 1, "b"),
+			navigation(10, 0, "c"),
+			int32sCommand(sessionCommandSetSelectedNavigationIndex, 10, 0),
+			timeCommand(sessionCommandTabClosed, 10, 0, at(1)),
+		}, map[string]time.Time{"c": at(1)}},
+		{"pruned from back", []snssCommand{
+			navigation(10, 0, "a"),
+			navigation(10, 1, "b"),
+			int32sCommand(sessionCommandSetSelectedNavigationIndex, 10, 1),
+			int32sCommand(sessionCommandTabNavigationPathPrunedFromBack, 10, 1),
+			timeCommand(sessionCommandTabClosed, 10, 0, at(1)),
+		}, map[string]time.Time{"a": at(1)}},
+		{"pruned from front", []snssCommand{
+			navigation(10, 0, "a"),
+			navigation(10, 1, "b"),
+			int32sCommand(sessionCommandSetSelectedNavigationIndex, 10, 0),
+			int32sCommand(sessionCommandTabNavigationPathPrunedFromFront, 10, 1),
+			timeCommand(sessionCommandTabClosed, 10, 0, at(1)),
+		}, map[string]time.Time{"b": at(1)}},
+		{"closed without navigations", []snssCommand{
+			timeCommand(sessionCommandTabClosed, 10, 0, at(1)),
+		}, map[string]time.Time{}},
+		// malformed commands are skipped
+		{"short payloads", []snssCommand{
+			navigation(10, 0, "a"),
+			{ID: sessionCommandUpdateTabNavigation, Payload: []byte{1}},
+			{ID: sessionCommandTabNavigationPathPruned, Payload: []byte{10, 0, 0, 0}},
+			int32sCommand(sessionCommandTabClosed, 10),
+		}, map[string]time.Time{}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]time.Time)
+			for _, tab := range replaySession(tt.commands) {
+				got[tab.URL] = tab.ClosedAt
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("replaySession() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayTabRestore(t *testing.T) {
+	at := func(seconds int) time.Time {
+		return sessionTime.Add(time.Duration(seconds) * time.Second).Local()
+	}
+
+	navigation := func(tab int32, index int32, url string) snssCommand {
+		return navigationCommand(tabRestoreCommandUpdateTabNavigation, tab, index, url, url)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		commands []snssCommand
+		want     []string
+	}{
+		{"closed", []snssCommand{
+			timeCommand(tabRestoreCommandSelectedNavigationInTab, 1, 1, at(1)),
+			navigation(1, 0, "a"),
+			navigation(1, 1, "b"),
+			timeCommand(tabRestoreCommandSelectedNavigationInTab, 2, 0, at(2)),
+			navigation(2, 0, "c"),
+		}, []string{"b", "c"}},
+		{"restored", []snssCommand{
+			timeCommand(tabRestoreCommandSelectedNavigationInTab, 1, 0, at(1)),
+			navigation(1, 0, "a"),
+			timeCommand(tabRestoreCommandSelectedNavigationInTab, 2, 0, at(2)),
+			navigation(2, 0, "b"),
+			int32sCommand(tabRestoreCommandRestoredEntry, 1),
+		}, []string{"b"}},
+		// navigations only belong to the tab they follow
+		{"navigation of another tab", []snssCommand{
+			navigation(1, 0, "a"),
+			timeCommand(tabRestoreCommandSelectedNavigationInTab, 2, 0, at(2)),
+			navigation(1, 0, "b"),
+			navigation(2, 0, "c"),
+		}, []string{"c"}},
+		{"short payloads", []snssCommand{
+			int32sCommand(tabRestoreCommandSelectedNavigationInTab, 1),
+			navigation(1, 0, "a"),
+			{ID: tabRestoreCommandRestoredEntry, Payload: []byte{1}},
+		}, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, tab := range replayTabRestore(tt.commands) {
+				got = append(got, tab.URL)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("replayTabRestore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaySessionFile(t *testing.T) {
+	at := func(seconds int) time.Time {
+		return sessionTime.Add(time.Duration(seconds) * time.Second).Local()
+	}
+
+	for _, tt := range []struct {
+		file   string
+		replay func([]snssCommand) []ClosedTab
+		want   []ClosedTab
+	}{
+		{"Session_13370000000000001", replaySession, []ClosedTab{
+			{ClosedAt: at(-10), URL: "https://example.com/b", Title: "B page é", Navigations: []Navigation{
+				{0, "https://example.com/a", "A page"},
+				{1, "https://example.com/b", "B page é"},
+			}, id: 10, window: 1, selected: 1},
+			// the first navigation was pruned
+			{ClosedAt: at(-30), URL: "https://x.example.com/2", Title: "two", Navigations: []Navigation{
+				{0, "https://x.example.com/2", "two"},
+				{1, "https://x.example.com/3", "three"},
+			}, id: 13, window: 1},
+			// closed along with its window
+			{ClosedAt: at(-60), URL: "https://win.example.com/", Title: "In closed window", Navigations: []Navigation{
+				{0, "https://win.example.com/", "In closed window"},
+			}, id: 12, window: 2},
+		}},
+		{"Tabs_13370000000000001", replayTabRestore, []ClosedTab{
+			{ClosedAt: at(-10), URL: "https://example.com/b", Title: "B page é", Navigations: []Navigation{
+				{0, "https://example.com/a", "A page"},
+				{1, "https://example.com/b", "B page é"},
+			}, id: 102, selected: 1},
+			{ClosedAt: at(-3600), URL: "https://old.example.com/", Title: "Old tab", Navigations: []Navigation{
+				{0, "https://old.example.com/", "Old tab"},
+			}, id: 100},
+		}},
+	} {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := replaySessionFile(filepath.Join("testdata", tt.file), tt.replay)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sortClosedTabs(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// FuzzReadSNSS replays arbitrary session files, which are written by Arc as
+// it runs and may be cut short or come from another version.
+func FuzzReadSNSS(f *testing.F) {
+	for _, pattern := range []string{"Session_*", "Tabs_*"} {
+		files, _ := filepath.Glob(filepath.Join("testdata", pattern))
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(data)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		commands, err := readSNSS(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+
+		for _, replay := range []func([]snssCommand) []ClosedTab{replaySession, replayTabRestore} {
+			for _, tab := range replay(commands) {
+				if len(tab.Navigations) == 0 {
+					t.Fatalf("got closed tab %+v without navigations", tab)
+				}
+
+				if !sort.SliceIsSorted(tab.Navigations, func(i, j int) bool {
+					return tab.Navigations[i].Index < tab.Navigations[j].Index
+				}) {
+					t.Fatalf("got unsorted navigations %+v", tab.Navigations)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cli/go-gh/v2/pkg/tableprinter"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// Shortcut records that text typed into the address bar led to a url, as
+// used by the omnibox to suggest it again.
+type Shortcut struct {
+	Text       string    `json:"text"`
+	URL        string    `json:"url"`
+	Title      string    `json:"title"`
+	Hits       int       `json:"hits"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+}
+
+// loadShortcuts reads the Shortcuts database, most used first. Only
+// shortcuts whose text starts with prefix are returned, ignoring case.
+func loadShortcuts(path string, prefix string, location *time.Location) ([]Shortcut, error) {
+	db, cleanup, err := openSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	rows, err := db.Query(`SELECT text, url, description, number_of_hits, last_access_time
+		FROM omni_box_shortcuts
+		WHERE substr(lower(text), 1, length(?)) = lower(?)
+		ORDER BY number_of_hits DESC, last_access_time DESC`, prefix, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shortcuts: %w", err)
+	}
+	defer rows.Close()
+
+	var shortcuts []Shortcut
+	for rows.Next() {
+		var shortcut Shortcut
+		var lastAccessTime int64
+		if err := rows.Scan(&shortcut.Text, &shortcut.URL, &shortcut.Title, &shortcut.Hits, &lastAccessTime); err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		shortcut.LastUsedAt = chromeTime(lastAccessTime).In(location)
+		shortcuts = append(shortcuts, shortcut)
+	}
+
+	return shortcuts, rows.Err()
+}
+
+// withShortcuts returns a copy of the search ranking the urls that the fuzzy
+// pattern led to when typed in the address bar first, as the omnibox does.
+// Profiles without a Shortcuts database are searched as is.
+func (s historySearch) withShortcuts(path string) (historySearch, error) {
+	if s.Fuzzy == "" {
+		return s, nil
+	}
+
+	shortcuts, err := loadShortcuts(path, strings.TrimSpace(s.Fuzzy), time.UTC)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+
+	if err != nil {
+		return s, err
+	}
+
+	s.shortcuts = make(map[string]int)
+	for _, shortcut := range shortcuts {
+		s.shortcuts[shortcut.URL] += shortcut.Hits
+	}
+
+	return s, nil
+}
+
+func NewCmdHistoryShortcuts() *cobra.Command {
+	var flags struct {
+		limit int
+		tz    string
+		json  bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "shortcuts [text]",
+		Short: "List the urls reached by text typed in the address bar",
+		Long: `List the urls reached by text typed in the address bar.
+
+Arc remembers which suggestion was picked after typing some text, and
+suggests it first the next time the same text is typed. Passing text only
+lists the shortcuts starting with it.`,
+		Example: `  arc history shortcuts
+  arc history shortcuts jr`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			location, err := loadLocation(flags.tz)
+			if err != nil {
+				return err
+			}
+
+			profile, err := selectedProfile()
+			if err != nil {
+				return err
+			}
+
+			path, err := profile.Path("Shortcuts")
+			if err != nil {
+				return err
+			}
+
+			var prefix string
+			if len(args) > 0 {
+				prefix = args[0]
+			}
+
+			shortcuts, err := loadShortcuts(path, prefix, location)
+			if err != nil {
+				return err
+			}
+
+			if flags.limit > 0 && len(shortcuts) > flags.limit {
+				shortcuts = shortcuts[:flags.limit]
+			}
+
+			if flags.json {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				encoder.SetEscapeHTML(false)
+				return encoder.Encode(shortcuts)
+			}
+
+			var printer tableprinter.TablePrinter
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				printer = tableprinter.New(os.Stdout, false, 0)
+			} else {
+				w, _, err := term.GetSize(int(os.Stdout.Fd()))
+				if err != nil {
+					return err
+				}
+
+				printer = tableprinter.New(os.Stdout, true, w)
+			}
+
+			printer.AddHeader([]string{"Text", "URL", "Title", "Hits", "LastUsedAt"})
+			for _, shortcut := range shortcuts {
+				printer.AddField(shortcut.Text)
+				printer.AddField(shortcut.URL)
+				printer.AddField(shortcut.Title)
+				printer.AddField(strconv.Itoa(shortcut.Hits))
+				printer.AddField(formatTime(shortcut.LastUsedAt))
+				printer.EndRow()
+			}
+
+			return printer.Render()
+		},
+	}
+
+	cmd.Flags().IntVarP(&flags.limit, "limit", "l", 100, "limit")
+	cmd.Flags().StringVar(&flags.tz, "tz", "Local", "timezone used to display timestamps")
+	cmd.Flags().BoolVar(&flags.json, "json", false, "output as json")
+
+	return cmd
+}
+
+// TopSite is a site shown on the new tab page, ranked by how often and how
+// recently it was visited.
+type TopSite struct {
+	Rank      int      `json:"rank"`
+	URL       string   `json:"url"`
+	Title     string   `json:"title"`
+	Redirects []string `json:"redirects"`
+}
+
+func loadTopSites(path string) ([]TopSite, error) {
+	db, cleanup, err := openSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	rows, err := db.Query("SELECT url_rank, url, title, redirects FROM top_sites ORDER BY url_rank")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top sites: %w", err)
+	}
+	defer rows.Close()
+
+	var sites []TopSite
+	for rows.Next() {
+		var site TopSite
+		var redirects string
+		if err := rows.Scan(&site.Rank, &site.URL, &site.Title, &redirects); err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		// ranks start at 0, and redirects are stored space separated, ending
+		// with the url itself
+		site.Rank++
+		site.Redirects = strings.Fields(redirects)
+		sites = append(sites, site)
+	}
+
+	return sites, rows.Err()
+}
+
+func NewCmdHistoryTop() *cobra.Command {
+	var flags struct {
+		json bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "top",
+		Short: "List the top sites shown on the new tab page",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profile, err := selectedProfile()
+			if err != nil {
+				return err
+			}
+
+			path, err := profile.Path("Top Sites")
+			if err != nil {
+				return err
+			}
+
+			sites, err := loadTopSites(path)
+			if err != nil {
+				return err
+			}
+
+			if flags.json {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				encoder.SetEscapeHTML(false)
+				return encoder.Encode(sites)
+			}
+
+			var printer tableprinter.TablePrinter
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				printer = tableprinter.New(os.Stdout, false, 0)
+			} else {
+				w, _, err := term.GetSize(int(os.Stdout.Fd()))
+				if err != nil {
+					return err
+				}
+
+				printer = tableprinter.New(os.Stdout, true, w)
+			}
+
+			printer.AddHeader([]string{"Rank", "Title", "URL"})
+			for _, site := range sites {
+				printer.AddField(strconv.Itoa(site.Rank))
+				printer.AddField(site.Title)
+				printer.AddField(site.URL)
+				printer.EndRow()
+			}
+
+			return printer.Render()
+		},
+	}
+
+	cmd.Flags().BoolVar(&flags.json, "json", false, "output as json")
+
+	return cmd
+}
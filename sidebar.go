@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cli/go-gh/v2/pkg/tableprinter"
+	"github.com/mattn/go-isatty"
+	"github.com/pomdtr/arc/sidebar"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func loadSidebar() (*sidebar.Sidebar, error) {
+	path, err := dataPath("StorableSidebar.json")
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sidebar file: %w", err)
+	}
+
+	s, err := sidebar.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+
+	// the sidebar is shared by all profiles, only narrow it down when a
+	// profile was asked for
+	if profileFlag != "" && profileFlag != "all" {
+		profile, err := selectedProfile()
+		if err != nil {
+			return nil, err
+		}
+
+		s.FilterProfile(profile.Dir)
+	}
+
+	return s, nil
+}
+
+func NewCmdSidebar() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sidebar",
+		Short: "Read the sidebar from disk",
+	}
+
+	cmd.AddCommand(NewCmdSidebarTree())
+	cmd.AddCommand(NewCmdSidebarList())
+
+	return cmd
+}
+
+func NewCmdSidebarTree() *cobra.Command {
+	var flags struct {
+		Json bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "tree",
+		Short: "Print the sidebar as a tree",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := loadSidebar()
+			if err != nil {
+				return err
+			}
+
+			tree := s.Tree()
+			if flags.Json {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				encoder.SetEscapeHTML(false)
+				return encoder.Encode(tree)
+			}
+
+			var print func(nodes []sidebar.Node, depth int)
+			print = func(nodes []sidebar.Node, depth int) {
+				indent := strings.Repeat("  ", depth)
+				for _, node := range nodes {
+					if node.Type == "folder" {
+						fmt.Printf("%s%s/\n", indent, node.Title)
+						print(node.Children, depth+1)
+						continue
+					}
+
+					fmt.Printf("%s%s (%s)\n", indent, node.Title, node.URL)
+				}
+			}
+
+			fmt.Println("Favorites")
+			print(tree.Favorites, 1)
+
+			for _, space := range tree.Spaces {
+				fmt.Println(space.Title)
+				print(space.Pinned, 1)
+				if len(space.Unpinned) > 0 {
+					fmt.Println("  ---")
+					print(space.Unpinned, 1)
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&flags.Json, "json", false, "output as json")
+	return cmd
+}
+
+func NewCmdSidebarList() *cobra.Command {
+	var flags struct {
+		Json bool
+	}
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List sidebar items",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := loadSidebar()
+			if err != nil {
+				return err
+			}
+
+			entries := s.Tree().Entries()
+			if flags.Json {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				encoder.SetEscapeHTML(false)
+				return encoder.Encode(entries)
+			}
+
+			var printer tableprinter.TablePrinter
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				printer = tableprinter.New(os.Stdout, false, 0)
+			} else {
+				w, _, err := term.GetSize(int(os.Stdout.Fd()))
+				if err != nil {
+					return err
+				}
+
+				printer = tableprinter.New(os.Stdout, true, w)
+			}
+
+			printer.AddHeader([]string{"Type", "Location", "Space", "Folder", "Title", "URL"})
+			for _, entry := range entries {
+				printer.AddField(entry.Type)
+				printer.AddField(entry.Location)
+				printer.AddField(entry.Space)
+				printer.AddField(entry.Folder)
+				printer.AddField(entry.Title)
+				printer.AddField(entry.URL)
+				printer.EndRow()
+			}
+
+			return printer.Render()
+		},
+	}
+
+	cmd.Flags().BoolVar(&flags.Json, "json", false, "output as json")
+	return cmd
+}
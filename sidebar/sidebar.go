@@ -0,0 +1,370 @@
+// Package sidebar reads the sidebar Arc stores in StorableSidebar.json: its
+// spaces, folders, pinned tabs and favorites.
+package sidebar
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Arc stores dates as seconds since the Core Data reference date.
+var appleEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// AppleTime converts a date stored by Arc to a time.
+func AppleTime(seconds float64) time.Time {
+	return appleEpoch.Add(time.Duration(seconds * float64(time.Second)))
+}
+
+type Sidebar struct {
+	Containers []Container `json:"containers"`
+}
+
+type Container struct {
+	Spaces              []Space
+	Items               []Item
+	TopAppsContainerIDs []string
+}
+
+func (c *Container) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Spaces              []json.RawMessage `json:"spaces"`
+		Items               []json.RawMessage `json:"items"`
+		TopAppsContainerIDs []json.RawMessage `json:"topAppsContainerIDs"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	var err error
+	if c.Spaces, err = DecodeObjects[Space](raw.Spaces); err != nil {
+		return err
+	}
+	if c.Items, err = DecodeObjects[Item](raw.Items); err != nil {
+		return err
+	}
+	c.TopAppsContainerIDs = decodeStrings(raw.TopAppsContainerIDs)
+
+	return nil
+}
+
+type Space struct {
+	ID           string
+	Title        string
+	Emoji        string
+	Profile      string
+	ContainerIDs []string
+}
+
+func (s *Space) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		ID           string            `json:"id"`
+		Title        string            `json:"title"`
+		ContainerIDs []json.RawMessage `json:"containerIDs"`
+		Profile      struct {
+			Custom *struct {
+				Value struct {
+					DirectoryBasename string `json:"directoryBasename"`
+				} `json:"_0"`
+			} `json:"custom"`
+		} `json:"profile"`
+		CustomInfo struct {
+			IconType struct {
+				Emoji string `json:"emoji_v2"`
+			} `json:"iconType"`
+		} `json:"customInfo"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	s.ID = raw.ID
+	s.Title = raw.Title
+	s.Emoji = raw.CustomInfo.IconType.Emoji
+	s.ContainerIDs = decodeStrings(raw.ContainerIDs)
+	s.Profile = "Default"
+	if raw.Profile.Custom != nil {
+		s.Profile = raw.Profile.Custom.Value.DirectoryBasename
+	}
+
+	return nil
+}
+
+// containerID looks up the id following a label such as "pinned" in the
+// containerIDs list, which alternates labels and ids.
+func (s Space) containerID(label string) string {
+	for i := 0; i+1 < len(s.ContainerIDs); i++ {
+		if s.ContainerIDs[i] == label {
+			return s.ContainerIDs[i+1]
+		}
+	}
+
+	return ""
+}
+
+func (s Space) PinnedContainerID() string {
+	return s.containerID("pinned")
+}
+
+func (s Space) UnpinnedContainerID() string {
+	return s.containerID("unpinned")
+}
+
+type Item struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	ParentID    string   `json:"parentID"`
+	ChildrenIDs []string `json:"childrenIds"`
+	CreatedAt   float64  `json:"createdAt"`
+	Data        struct {
+		Tab           *Tab      `json:"tab"`
+		List          *struct{} `json:"list"`
+		ItemContainer *struct{} `json:"itemContainer"`
+	} `json:"data"`
+}
+
+type Tab struct {
+	SavedURL         string  `json:"savedURL"`
+	SavedTitle       string  `json:"savedTitle"`
+	TimeLastActiveAt float64 `json:"timeLastActiveAt"`
+}
+
+func (i Item) Type() string {
+	switch {
+	case i.Data.Tab != nil:
+		return "tab"
+	case i.Data.List != nil:
+		return "folder"
+	case i.Data.ItemContainer != nil:
+		return "container"
+	default:
+		return "other"
+	}
+}
+
+func (i Item) DisplayTitle() string {
+	if i.Title != "" {
+		return i.Title
+	}
+
+	if i.Data.Tab != nil {
+		return i.Data.Tab.SavedTitle
+	}
+
+	return ""
+}
+
+func (i Item) URL() string {
+	if i.Data.Tab != nil {
+		return i.Data.Tab.SavedURL
+	}
+
+	return ""
+}
+
+// DecodeObjects decodes the objects of a list, which Arc interleaves with
+// their ids.
+func DecodeObjects[T any](raw []json.RawMessage) ([]T, error) {
+	var objects []T
+	for _, message := range raw {
+		if len(message) == 0 || message[0] != '{' {
+			continue
+		}
+
+		var object T
+		if err := json.Unmarshal(message, &object); err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+
+	return objects, nil
+}
+
+func decodeStrings(raw []json.RawMessage) []string {
+	var values []string
+	for _, message := range raw {
+		var value string
+		if err := json.Unmarshal(message, &value); err != nil {
+			continue
+		}
+		values = append(values, value)
+	}
+
+	return values
+}
+
+// Parse reads the sidebar of the content of StorableSidebar.json.
+func Parse(content []byte) (*Sidebar, error) {
+	var storable struct {
+		Sidebar Sidebar `json:"sidebar"`
+	}
+	if err := json.Unmarshal(content, &storable); err != nil {
+		return nil, fmt.Errorf("failed to parse sidebar file: %w", err)
+	}
+
+	return &storable.Sidebar, nil
+}
+
+// FilterProfile drops the spaces of other profiles. Favorites are shared by
+// all profiles and kept.
+func (s *Sidebar) FilterProfile(dir string) {
+	for i, container := range s.Containers {
+		var spaces []Space
+		for _, space := range container.Spaces {
+			if space.Profile == dir {
+				spaces = append(spaces, space)
+			}
+		}
+		s.Containers[i].Spaces = spaces
+	}
+}
+
+func (s *Sidebar) Spaces() []Space {
+	var spaces []Space
+	for _, container := range s.Containers {
+		spaces = append(spaces, container.Spaces...)
+	}
+
+	return spaces
+}
+
+func (s *Sidebar) Items() map[string]Item {
+	items := make(map[string]Item)
+	for _, container := range s.Containers {
+		for _, item := range container.Items {
+			items[item.ID] = item
+		}
+	}
+
+	return items
+}
+
+// Node is a tab or folder of the sidebar, with its children resolved.
+type Node struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	URL      string `json:"url,omitempty"`
+	Icon     string `json:"icon,omitempty"`
+	Children []Node `json:"children,omitempty"`
+}
+
+type SpaceTree struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Profile  string `json:"profile"`
+	Pinned   []Node `json:"pinned"`
+	Unpinned []Node `json:"unpinned"`
+}
+
+type Tree struct {
+	Favorites []Node      `json:"favorites"`
+	Spaces    []SpaceTree `json:"spaces"`
+}
+
+func (s *Sidebar) Tree() Tree {
+	items := s.Items()
+
+	var build func(ids []string) []Node
+	build = func(ids []string) []Node {
+		var nodes []Node
+		for _, id := range ids {
+			item, ok := items[id]
+			if !ok {
+				continue
+			}
+
+			switch item.Type() {
+			case "tab":
+				nodes = append(nodes, Node{
+					ID:    item.ID,
+					Type:  "tab",
+					Title: item.DisplayTitle(),
+					URL:   item.URL(),
+				})
+			case "folder":
+				nodes = append(nodes, Node{
+					ID:       item.ID,
+					Type:     "folder",
+					Title:    item.DisplayTitle(),
+					Children: build(item.ChildrenIDs),
+				})
+			}
+		}
+
+		return nodes
+	}
+
+	var tree Tree
+	for _, container := range s.Containers {
+		for _, id := range container.TopAppsContainerIDs {
+			if item, ok := items[id]; ok {
+				tree.Favorites = append(tree.Favorites, build(item.ChildrenIDs)...)
+			}
+		}
+	}
+
+	for _, space := range s.Spaces() {
+		spaceTree := SpaceTree{
+			ID:      space.ID,
+			Title:   space.Title,
+			Profile: space.Profile,
+		}
+
+		if item, ok := items[space.PinnedContainerID()]; ok {
+			spaceTree.Pinned = build(item.ChildrenIDs)
+		}
+
+		if item, ok := items[space.UnpinnedContainerID()]; ok {
+			spaceTree.Unpinned = build(item.ChildrenIDs)
+		}
+
+		tree.Spaces = append(tree.Spaces, spaceTree)
+	}
+
+	return tree
+}
+
+// Entry is a flattened Node, locating it by space and folder.
+type Entry struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	URL      string `json:"url,omitempty"`
+	Location string `json:"location"`
+	Space    string `json:"space"`
+	Folder   string `json:"folder"`
+}
+
+func (t Tree) Entries() []Entry {
+	var entries []Entry
+
+	var walk func(nodes []Node, location string, space string, folder []string)
+	walk = func(nodes []Node, location string, space string, folder []string) {
+		for _, node := range nodes {
+			entries = append(entries, Entry{
+				ID:       node.ID,
+				Type:     node.Type,
+				Title:    node.Title,
+				URL:      node.URL,
+				Location: location,
+				Space:    space,
+				Folder:   strings.Join(folder, "/"),
+			})
+
+			if node.Type == "folder" {
+				walk(node.Children, location, space, append(folder[:len(folder):len(folder)], node.Title))
+			}
+		}
+	}
+
+	walk(t.Favorites, "topApp", "", nil)
+	for _, space := range t.Spaces {
+		walk(space.Pinned, "pinned", space.Title, nil)
+		walk(space.Unpinned, "unpinned", space.Title, nil)
+	}
+
+	return entries
+}
@@ -0,0 +1,122 @@
+package sidebar
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func loadTestSidebar(t *testing.T) *Sidebar {
+	t.Helper()
+
+	content, err := os.ReadFile("testdata/StorableSidebar.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sidebar, err := Parse(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sidebar
+}
+
+func TestSidebarTree(t *testing.T) {
+	tree := loadTestSidebar(t).Tree()
+
+	favorites := []Node{
+		{ID: "F1", Type: "tab", Title: "Gmail", URL: "https://mail.google.com/"},
+	}
+	if !reflect.DeepEqual(tree.Favorites, favorites) {
+		t.Errorf("favorites = %+v, want %+v", tree.Favorites, favorites)
+	}
+
+	if len(tree.Spaces) != 2 {
+		t.Fatalf("got %d spaces, want 2", len(tree.Spaces))
+	}
+
+	research := tree.Spaces[0]
+	if research.Title != "Research" || research.Profile != "Default" {
+		t.Errorf("space = %s (%s), want Research (Default)", research.Title, research.Profile)
+	}
+
+	pinned := []Node{
+		{ID: "D1", Type: "folder", Title: "Papers", Children: []Node{
+			{ID: "T2", Type: "tab", Title: "A <paper>", URL: "https://example.com/a&b"},
+			{ID: "D2", Type: "folder", Title: "Nested", Children: []Node{
+				{ID: "T3", Type: "tab", Title: "arc", URL: "https://github.com/pomdtr/arc"},
+			}},
+		}},
+		{ID: "T1", Type: "tab", Title: "Custom name", URL: "https://arxiv.org/"},
+	}
+	if !reflect.DeepEqual(research.Pinned, pinned) {
+		t.Errorf("pinned = %+v, want %+v", research.Pinned, pinned)
+	}
+
+	unpinned := []Node{
+		{ID: "T4", Type: "tab", Title: "HN", URL: "https://news.ycombinator.com/"},
+	}
+	if !reflect.DeepEqual(research.Unpinned, unpinned) {
+		t.Errorf("unpinned = %+v, want %+v", research.Unpinned, unpinned)
+	}
+
+	if work := tree.Spaces[1]; work.Title != "Work" || work.Profile != "Profile 1" {
+		t.Errorf("space = %s (%s), want Work (Profile 1)", work.Title, work.Profile)
+	}
+}
+
+func TestSidebarEntries(t *testing.T) {
+	entries := loadTestSidebar(t).Tree().Entries()
+
+	type located struct {
+		ID, Location, Space, Folder string
+	}
+
+	var got []located
+	for _, entry := range entries {
+		got = append(got, located{entry.ID, entry.Location, entry.Space, entry.Folder})
+	}
+
+	want := []located{
+		{"F1", "topApp", "", ""},
+		{"D1", "pinned", "Research", ""},
+		{"T2", "pinned", "Research", "Papers"},
+		{"D2", "pinned", "Research", "Papers"},
+		{"T3", "pinned", "Research", "Papers/Nested"},
+		{"T1", "pinned", "Research", ""},
+		{"T4", "unpinned", "Research", ""},
+		{"T5", "pinned", "Work", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %+v, want %+v", got, want)
+	}
+}
+
+func TestSidebarFilterProfile(t *testing.T) {
+	for _, tt := range []struct {
+		profile string
+		spaces  []string
+	}{
+		{"Default", []string{"Research"}},
+		{"Profile 1", []string{"Work"}},
+		{"Profile 2", nil},
+	} {
+		sidebar := loadTestSidebar(t)
+		sidebar.FilterProfile(tt.profile)
+		tree := sidebar.Tree()
+
+		var spaces []string
+		for _, space := range tree.Spaces {
+			spaces = append(spaces, space.Title)
+		}
+
+		if !reflect.DeepEqual(spaces, tt.spaces) {
+			t.Errorf("%s: spaces = %v, want %v", tt.profile, spaces, tt.spaces)
+		}
+
+		if len(tree.Favorites) != 1 {
+			t.Errorf("%s: got %d favorites, want them shared across profiles", tt.profile, len(tree.Favorites))
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Arc may keep its databases locked while running, and recent transactions
+// may still live in the write-ahead log or a rollback journal next to them,
+// so databases that cannot be read in place are read from a copy of all
+// three files.
+var snapshotSuffixes = []string{"", "-wal", "-journal"}
+
+// snapshotStamp identifies the current state of a database and its journals.
+func snapshotStamp(path string) (string, error) {
+	var parts []string
+	for _, suffix := range snapshotSuffixes {
+		info, err := os.Stat(path + suffix)
+		if errors.Is(err, fs.ErrNotExist) && suffix != "" {
+			continue
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("failed to open db file: %w", err)
+		}
+
+		parts = append(parts, fmt.Sprintf("%s:%d:%d", suffix, info.Size(), info.ModTime().UnixNano()))
+	}
+
+	return strings.Join(parts, " "), nil
+}
+
+func snapshotHash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:8])
+}
+
+// snapshotFile returns the path of an up to date copy of a database of the
+// profile. Copies are cached by the size and modification time of the
+// database and its journals, so they are only refreshed once Arc writes to
+// it. Only History is read often enough to be worth caching, other databases
+// should go through openSnapshot.
+func snapshotFile(path string) (string, error) {
+	snapshotDir, err := snapshotCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(snapshotDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	stamp, err := snapshotStamp(path)
+	if err != nil {
+		return "", err
+	}
+
+	prefix := snapshotHash(path)
+	snapshot := filepath.Join(snapshotDir, fmt.Sprintf("%s-%s.sqlite", prefix, snapshotHash(stamp)))
+	if _, err := os.Stat(snapshot); errors.Is(err, fs.ErrNotExist) {
+		if err := createSnapshot(path, snapshot, stamp); err != nil {
+			return "", err
+		}
+
+		stale, _ := filepath.Glob(filepath.Join(snapshotDir, prefix+"-*.sqlite"))
+		for _, file := range stale {
+			if file != snapshot {
+				os.Remove(file)
+			}
+		}
+	} else if err != nil {
+		return "", fmt.Errorf("failed to open snapshot: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+func snapshotCacheDir() (string, error) {
+	cacheDir, err := appCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(cacheDir, "snapshots"), nil
+}
+
+// removeSnapshots removes the cached copies of a database, for when they
+// hold data that should not outlive it.
+func removeSnapshots(path string) error {
+	snapshotDir, err := snapshotCacheDir()
+	if err != nil {
+		return err
+	}
+
+	snapshots, _ := filepath.Glob(filepath.Join(snapshotDir, snapshotHash(path)+"-*.sqlite"))
+	for _, snapshot := range snapshots {
+		if err := os.Remove(snapshot); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove snapshot: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// isWAL reports whether a database uses a write-ahead log, which lets it be
+// read while Arc writes to it.
+func isWAL(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	// the file format read and write versions are 2 in wal mode
+	header := make([]byte, 20)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+
+	return header[18] == 2 && header[19] == 2
+}
+
+// snapshotSource returns a dsn reading a database of the profile without
+// getting in the way of Arc. Databases in wal mode are read in place, as
+// readers never block the writer. Others are read from a copy: the one
+// cached by snapshotFile for History, and a temporary one removed by the
+// returned cleanup for the rest, so that no other data is kept around.
+func snapshotSource(path string) (string, func(), error) {
+	if _, err := os.Stat(path); err != nil {
+		return "", nil, fmt.Errorf("failed to open db file: %w", err)
+	}
+
+	if isWAL(path) {
+		if db, _, err := openInPlace(path); err == nil {
+			db.Close()
+			return inPlaceDSN(path), func() {}, nil
+		}
+	}
+
+	var snapshot string
+	cleanup := func() {}
+	if filepath.Base(path) == "History" {
+		var err error
+		if snapshot, err = snapshotFile(path); err != nil {
+			return "", nil, err
+		}
+	} else {
+		dir, err := os.MkdirTemp("", "arc-snapshot-*")
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to create tempdir: %w", err)
+		}
+
+		stamp, err := snapshotStamp(path)
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", nil, err
+		}
+
+		snapshot = filepath.Join(dir, "snapshot.sqlite")
+		if err := createSnapshot(path, snapshot, stamp); err != nil {
+			os.RemoveAll(dir)
+			return "", nil, err
+		}
+
+		cleanup = func() {
+			os.RemoveAll(dir)
+		}
+	}
+
+	// the snapshot never changes once created, which lets sqlite skip locking
+	dsn := url.URL{Scheme: "file", Path: snapshot, RawQuery: "mode=ro&immutable=1"}
+	return dsn.String(), cleanup, nil
+}
+
+func inPlaceDSN(path string) string {
+	dsn := url.URL{Scheme: "file", Path: path, RawQuery: "mode=ro"}
+	return dsn.String()
+}
+
+// openInPlace opens a database of the profile read-only without copying it,
+// which fails while Arc holds an exclusive lock on it. Outside of wal mode
+// readers hold off Arc's writes, so this is only meant for short reads.
+func openInPlace(path string) (*sql.DB, func(), error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, fmt.Errorf("failed to open db file: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", inPlaceDSN(path))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open db: %w", err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master").Scan(&count); err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("failed to open db: %w", err)
+	}
+
+	return db, func() {
+		db.Close()
+	}, nil
+}
+
+// openSnapshot opens a database of the profile read-only, see
+// snapshotSource.
+func openSnapshot(path string) (*sql.DB, func(), error) {
+	dsn, cleanup, err := snapshotSource(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to open db: %w", err)
+	}
+
+	return db, func() {
+		db.Close()
+		cleanup()
+	}, nil
+}
+
+// createSnapshot copies a database along with its journals, folds them into
+// the copy and moves it to the snapshot path.
+func createSnapshot(path string, snapshot string, stamp string) error {
+	tempfile, err := os.CreateTemp(filepath.Dir(snapshot), "snapshot-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create tempfile: %w", err)
+	}
+	tempfile.Close()
+
+	defer func() {
+		for _, suffix := range snapshotSuffixes {
+			os.Remove(tempfile.Name() + suffix)
+		}
+	}()
+
+	// Arc may checkpoint while the files are copied, retry until they are
+	// copied in a consistent state
+	for attempt := 0; attempt < 3; attempt++ {
+		for _, suffix := range snapshotSuffixes {
+			os.Remove(tempfile.Name() + suffix)
+			if err := copyFile(path+suffix, tempfile.Name()+suffix); err != nil && !(errors.Is(err, fs.ErrNotExist) && suffix != "") {
+				return fmt.Errorf("failed to copy db file: %w", err)
+			}
+		}
+
+		current, err := snapshotStamp(path)
+		if err != nil {
+			return err
+		}
+
+		if current == stamp {
+			break
+		}
+		stamp = current
+	}
+
+	// switching away from wal replays the log, and reading rolls back any
+	// journal left by an unfinished transaction
+	db, err := sql.Open("sqlite", tempfile.Name())
+	if err != nil {
+		return fmt.Errorf("failed to open db: %w", err)
+	}
+
+	_, err = db.Exec("PRAGMA journal_mode = DELETE")
+	db.Close()
+	if err != nil {
+		return fmt.Errorf("failed to replay journal: %w", err)
+	}
+
+	if err := os.Rename(tempfile.Name(), snapshot); err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	return nil
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// historySchema is the part of the schema of a Chromium History database
+// read by arc.
+const historySchema = `
+CREATE TABLE urls(id INTEGER PRIMARY KEY AUTOINCREMENT,url LONGVARCHAR,title LONGVARCHAR,visit_count INTEGER DEFAULT 0 NOT NULL,typed_count INTEGER DEFAULT 0 NOT NULL,last_visit_time INTEGER NOT NULL,hidden INTEGER DEFAULT 0 NOT NULL);
+CREATE INDEX urls_url_index ON urls (url);
+CREATE TABLE visits(id INTEGER PRIMARY KEY AUTOINCREMENT,url INTEGER NOT NULL,visit_time INTEGER NOT NULL,from_visit INTEGER,external_referrer_url TEXT,transition INTEGER DEFAULT 0 NOT NULL,segment_id INTEGER,visit_duration INTEGER DEFAULT 0 NOT NULL,incremented_omnibox_typed_score BOOLEAN DEFAULT FALSE NOT NULL,opener_visit INTEGER,originator_cache_guid TEXT,originator_visit_id INTEGER,originator_from_visit INTEGER,originator_opener_visit INTEGER,is_known_to_sync BOOLEAN DEFAULT FALSE NOT NULL,consider_for_ntp_most_visited BOOLEAN DEFAULT FALSE NOT NULL,visited_link_id INTEGER DEFAULT 0 NOT NULL);
+CREATE INDEX visits_url_index ON visits (url);
+CREATE INDEX visits_from_index ON visits (from_visit);
+CREATE INDEX visits_time_index ON visits (visit_time);
+CREATE TABLE visit_source(id INTEGER PRIMARY KEY,source INTEGER NOT NULL);
+CREATE TABLE keyword_search_terms (keyword_id INTEGER NOT NULL,url_id INTEGER NOT NULL,term LONGVARCHAR NOT NULL,normalized_term LONGVARCHAR NOT NULL);
+`
+
+// fixtureWords are the most common words of the titles of the History
+// fixture, the rest of its vocabulary being made up.
+var fixtureWords = []string{
+	"the", "and", "github", "google", "search", "docs", "news", "video", "mail", "home",
+	"issue", "pull", "request", "youtube", "wikipedia", "reddit", "stack", "overflow", "api", "reference",
+	"sqlite", "arc", "browser", "history", "index", "blog", "post", "guide", "tutorial", "maps",
+	"calendar", "drive", "sheet", "slides", "notion", "linear", "figma", "slack", "golang", "rust",
+	"python", "javascript", "react", "docker", "kubernetes", "release", "notes", "changelog", "pricing", "login",
+}
+
+// writeHistoryFixture creates a History database at path with n urls
+// visited twice each, one minute apart, in the given journal mode. As in
+// real history, title words follow Zipf's law: a few are common, most are
+// rare.
+func writeHistoryFixture(tb testing.TB, path string, n int, journalMode string) {
+	tb.Helper()
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("PRAGMA journal_mode = " + journalMode); err != nil {
+		tb.Fatal(err)
+	}
+
+	if _, err := db.Exec(historySchema); err != nil {
+		tb.Fatal(err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	urls, err := tx.Prepare("INSERT INTO urls (id, url, title, visit_count, typed_count, last_visit_time) VALUES (?, ?, ?, 2, ?, ?)")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	visits, err := tx.Prepare("INSERT INTO visits (url, visit_time, from_visit, transition, visit_duration) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	vocabulary := append([]string(nil), fixtureWords...)
+	for len(vocabulary) < 5000 {
+		vocabulary = append(vocabulary, fmt.Sprintf("w%d", len(vocabulary)))
+	}
+
+	zipf := rand.NewZipf(rand.New(rand.NewSource(1)), 1.1, 1, uint64(len(vocabulary)-1))
+	word := func() string {
+		return vocabulary[zipf.Uint64()]
+	}
+
+	// 2024-01-01 in chrome time
+	start := int64(13348540800000000)
+	for i := 1; i <= n; i++ {
+		title := strings.Join([]string{word(), word(), word(), word()}, " ")
+		first := start + int64(i)*1e6
+		last := first + 60*1e6
+
+		if _, err := urls.Exec(i, fmt.Sprintf("https://site%d.example.com/%s/%d", i%997, word(), i), title, i%3, last); err != nil {
+			tb.Fatal(err)
+		}
+
+		if _, err := visits.Exec(i, first, 0, 1, 5e6); err != nil {
+			tb.Fatal(err)
+		}
+
+		if _, err := visits.Exec(i, last, 0, 0, 0); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func countURLs(tb testing.TB, db *sql.DB) int {
+	tb.Helper()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM urls").Scan(&count); err != nil {
+		tb.Fatal(err)
+	}
+
+	return count
+}
+
+func cachedSnapshots(tb testing.TB) []string {
+	tb.Helper()
+
+	dir, err := snapshotCacheDir()
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	return files
+}
+
+func TestOpenSnapshot(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		journalMode string
+		cached      int
+	}{
+		{"History", "WAL", 0},
+		{"History", "DELETE", 1},
+		{"Web Data", "WAL", 0},
+		{"Web Data", "DELETE", 0},
+	} {
+		t.Run(tt.name+"/"+tt.journalMode, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", t.TempDir())
+			t.Setenv("TMPDIR", t.TempDir())
+
+			path := filepath.Join(t.TempDir(), tt.name)
+			writeHistoryFixture(t, path, 10, tt.journalMode)
+
+			db, cleanup, err := openSnapshot(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if count := countURLs(t, db); count != 10 {
+				t.Errorf("got %d urls, want 10", count)
+			}
+			cleanup()
+
+			if cached := cachedSnapshots(t); len(cached) != tt.cached {
+				t.Errorf("got cached snapshots %v, want %d", cached, tt.cached)
+			}
+
+			temp, _ := filepath.Glob(filepath.Join(os.Getenv("TMPDIR"), "*"))
+			if len(temp) > 0 {
+				t.Errorf("temporary copies left behind: %v", temp)
+			}
+		})
+	}
+}
+
+func TestOpenSnapshotWhileWriting(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		lockingMode string
+		cached      int
+	}{
+		{"shared", "NORMAL", 0},
+		{"exclusive", "EXCLUSIVE", 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+			path := filepath.Join(t.TempDir(), "History")
+			writeHistoryFixture(t, path, 10, "WAL")
+
+			// stands in for Arc, keeping the last insert in the log
+			writer, err := sql.Open("sqlite", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer writer.Close()
+			writer.SetMaxOpenConns(1)
+
+			if _, err := writer.Exec("PRAGMA locking_mode = " + tt.lockingMode); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := writer.Exec("INSERT INTO urls (url, last_visit_time) VALUES ('https://new.example.com/', 0)"); err != nil {
+				t.Fatal(err)
+			}
+
+			db, cleanup, err := openSnapshot(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cleanup()
+
+			if count := countURLs(t, db); count != 11 {
+				t.Errorf("got %d urls, want 11", count)
+			}
+
+			if cached := cachedSnapshots(t); len(cached) != tt.cached {
+				t.Errorf("got cached snapshots %v, want %d", cached, tt.cached)
+			}
+		})
+	}
+}
+
+func TestRemoveSnapshots(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	history := filepath.Join(dir, "History")
+	other := filepath.Join(dir, "Other", "History")
+	os.Mkdir(filepath.Dir(other), 0700)
+	writeHistoryFixture(t, history, 1, "DELETE")
+	writeHistoryFixture(t, other, 1, "DELETE")
+
+	for _, path := range []string{history, other} {
+		if _, err := snapshotFile(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := removeSnapshots(history); err != nil {
+		t.Fatal(err)
+	}
+
+	cached := cachedSnapshots(t)
+	if len(cached) != 1 || !strings.HasPrefix(filepath.Base(cached[0]), snapshotHash(other)) {
+		t.Errorf("got cached snapshots %v, want only the one of %s", cached, other)
+	}
+}
+
+// BenchmarkOpenSnapshot compares reading a History database through a full
+// temporary copy, as done before snapshots, to reading it in place in wal
+// mode and from the cached copy otherwise.
+func BenchmarkOpenSnapshot(b *testing.B) {
+	b.Setenv("XDG_CACHE_HOME", b.TempDir())
+
+	const n = 50000
+	dir := b.TempDir()
+	os.Mkdir(filepath.Join(dir, "wal"), 0700)
+	os.Mkdir(filepath.Join(dir, "delete"), 0700)
+	wal := filepath.Join(dir, "wal", "History")
+	rollback := filepath.Join(dir, "delete", "History")
+	writeHistoryFixture(b, wal, n, "WAL")
+	writeHistoryFixture(b, rollback, n, "DELETE")
+
+	b.Run("copy", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tempfile := filepath.Join(b.TempDir(), "snapshot.sqlite")
+			if err := copyFile(rollback, tempfile); err != nil {
+				b.Fatal(err)
+			}
+
+			db, err := sql.Open("sqlite", tempfile)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			countURLs(b, db)
+			db.Close()
+			os.Remove(tempfile)
+		}
+	})
+
+	for _, bb := range []struct {
+		name string
+		path string
+	}{
+		{"in-place", wal},
+		{"cached", rollback},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				db, cleanup, err := openSnapshot(bb.path)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				countURLs(b, db)
+				cleanup()
+			}
+		})
+	}
+}
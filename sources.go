@@ -0,0 +1,286 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// historySource is a browser whose history can be searched. Databases are
+// exposed with the urls and visits tables of Chromium, so that searches run
+// unchanged against all of them.
+type historySource interface {
+	// Each calls fn with the history database of every profile of the
+	// browser.
+	Each(fn func(profile string, db *sql.DB) error) error
+}
+
+// profileFileSource is implemented by Chromium based sources, which keep
+// databases such as Favicons and Shortcuts next to History.
+type profileFileSource interface {
+	// ProfileFile returns the path of a file in the dir of a profile.
+	ProfileFile(profile string, name string) (string, error)
+}
+
+// historySourceNames lists the browsers accepted by --source.
+var historySourceNames = []string{"arc", "chrome", "chromium", "brave", "edge", "vivaldi", "firefox"}
+
+type arcSource struct {
+	archive bool
+}
+
+func (s arcSource) Each(fn func(profile string, db *sql.DB) error) error {
+	return eachHistory(s.archive, func(profile Profile, db *sql.DB) error {
+		return fn(profile.Dir, db)
+	})
+}
+
+func (s arcSource) ProfileFile(profile string, name string) (string, error) {
+	return Profile{Dir: profile}.Path(name)
+}
+
+// chromiumSource reads the History database of every profile of a Chromium
+// based browser.
+type chromiumSource struct {
+	dir string
+}
+
+func (s chromiumSource) Each(fn func(profile string, db *sql.DB) error) error {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*", "History"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		db, cleanup, err := openSnapshot(path)
+		if err != nil {
+			return err
+		}
+
+		err = fn(filepath.Base(filepath.Dir(path)), db)
+		cleanup()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s chromiumSource) ProfileFile(profile string, name string) (string, error) {
+	return filepath.Join(s.dir, profile, name), nil
+}
+
+// firefoxVisitTypes maps the visit types of Firefox to Chromium transitions.
+const firefoxVisitTypes = `CASE visit_type
+	WHEN 2 THEN 1
+	WHEN 3 THEN 2
+	WHEN 4 THEN 3
+	WHEN 8 THEN 4
+	WHEN 9 THEN 8
+	ELSE 0
+END`
+
+// firefoxViews present the places database of Firefox, which stores
+// microseconds since the Unix epoch, as Chromium tables.
+var firefoxViews = []string{
+	fmt.Sprintf(`CREATE TEMP VIEW urls AS SELECT
+		id,
+		url,
+		COALESCE(title, '') AS title,
+		visit_count,
+		typed AS typed_count,
+		hidden,
+		CASE WHEN last_visit_date IS NULL THEN 0 ELSE last_visit_date + %d END AS last_visit_time
+		FROM moz_places`, chromeEpochOffset),
+	fmt.Sprintf(`CREATE TEMP VIEW visits AS SELECT
+		id,
+		place_id AS url,
+		visit_date + %d AS visit_time,
+		from_visit,
+		%s AS transition,
+		0 AS visit_duration
+		FROM moz_historyvisits`, chromeEpochOffset, firefoxVisitTypes),
+}
+
+// firefoxSource reads the places database of every Firefox profile.
+type firefoxSource struct {
+	dir string
+}
+
+func (s firefoxSource) Each(fn func(profile string, db *sql.DB) error) error {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*", "places.sqlite"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		db, cleanup, err := openSnapshot(path)
+		if err != nil {
+			return err
+		}
+
+		// temporary views only exist on the connection creating them
+		db.SetMaxOpenConns(1)
+		for _, view := range firefoxViews {
+			if _, err := db.Exec(view); err != nil {
+				cleanup()
+				return fmt.Errorf("failed to read places: %w", err)
+			}
+		}
+
+		err = fn(filepath.Base(filepath.Dir(path)), db)
+		cleanup()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// browserDir returns where a browser keeps its profiles on the current
+// platform.
+func browserDir(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home dir: %w", err)
+	}
+
+	dirs := map[string]map[string]string{
+		"darwin": {
+			"chrome":   filepath.Join(home, "Library", "Application Support", "Google", "Chrome"),
+			"chromium": filepath.Join(home, "Library", "Application Support", "Chromium"),
+			"brave":    filepath.Join(home, "Library", "Application Support", "BraveSoftware", "Brave-Browser"),
+			"edge":     filepath.Join(home, "Library", "Application Support", "Microsoft Edge"),
+			"vivaldi":  filepath.Join(home, "Library", "Application Support", "Vivaldi"),
+			"firefox":  filepath.Join(home, "Library", "Application Support", "Firefox", "Profiles"),
+		},
+		"linux": {
+			"chrome":   filepath.Join(home, ".config", "google-chrome"),
+			"chromium": filepath.Join(home, ".config", "chromium"),
+			"brave":    filepath.Join(home, ".config", "BraveSoftware", "Brave-Browser"),
+			"edge":     filepath.Join(home, ".config", "microsoft-edge"),
+			"vivaldi":  filepath.Join(home, ".config", "vivaldi"),
+			"firefox":  filepath.Join(home, ".mozilla", "firefox"),
+		},
+		"windows": {
+			"chrome":   filepath.Join(os.Getenv("LOCALAPPDATA"), "Google", "Chrome", "User Data"),
+			"chromium": filepath.Join(os.Getenv("LOCALAPPDATA"), "Chromium", "User Data"),
+			"brave":    filepath.Join(os.Getenv("LOCALAPPDATA"), "BraveSoftware", "Brave-Browser", "User Data"),
+			"edge":     filepath.Join(os.Getenv("LOCALAPPDATA"), "Microsoft", "Edge", "User Data"),
+			"vivaldi":  filepath.Join(os.Getenv("LOCALAPPDATA"), "Vivaldi", "User Data"),
+			"firefox":  filepath.Join(os.Getenv("APPDATA"), "Mozilla", "Firefox", "Profiles"),
+		},
+	}
+
+	dir, ok := dirs[runtime.GOOS][name]
+	if !ok {
+		return "", fmt.Errorf("%s is not supported on %s", name, runtime.GOOS)
+	}
+
+	return dir, nil
+}
+
+// loadHistorySources resolves the names given to --source, skipping
+// browsers that are not installed when reading all of them.
+func loadHistorySources(names []string, archive bool) (map[string]historySource, error) {
+	all := len(names) == 1 && names[0] == "all"
+	if all {
+		names = historySourceNames
+	}
+
+	sources := make(map[string]historySource)
+	for _, name := range names {
+		if name == "arc" {
+			sources[name] = arcSource{archive: archive}
+			continue
+		}
+
+		found := false
+		for _, known := range historySourceNames {
+			found = found || known == name
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown source: %s (valid: all, %s)", name, strings.Join(historySourceNames, ", "))
+		}
+
+		dir, err := browserDir(name)
+		if err == nil {
+			_, err = os.Stat(dir)
+		}
+
+		if all && err != nil {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to find %s: %w", name, err)
+		}
+
+		if name == "firefox" {
+			sources[name] = firefoxSource{dir: dir}
+		} else {
+			sources[name] = chromiumSource{dir: dir}
+		}
+	}
+
+	return sources, nil
+}
+
+// dedupeHistory merges entries sharing an url, adding up their counts and
+// keeping the title of the latest visit.
+func dedupeHistory(entries []HistoryEntry) []HistoryEntry {
+	var merged []HistoryEntry
+	index := make(map[string]int)
+	for _, entry := range entries {
+		i, ok := index[entry.URL]
+		if !ok {
+			index[entry.URL] = len(merged)
+			merged = append(merged, entry)
+			continue
+		}
+
+		existing := &merged[i]
+		if entry.LastVisitedAt.After(existing.LastVisitedAt) {
+			existing.Title = entry.Title
+			existing.LastVisitedAt = entry.LastVisitedAt
+		}
+
+		if !entry.FirstVisitedAt.IsZero() && (existing.FirstVisitedAt.IsZero() || entry.FirstVisitedAt.Before(existing.FirstVisitedAt)) {
+			existing.FirstVisitedAt = entry.FirstVisitedAt
+		}
+
+		existing.VisitCount += entry.VisitCount
+		existing.TypedCount += entry.TypedCount
+		existing.DwellSeconds += entry.DwellSeconds
+		existing.Score = max(existing.Score, entry.Score)
+		existing.Hidden = existing.Hidden && entry.Hidden
+		if existing.Icon == "" {
+			existing.Icon = entry.Icon
+		}
+
+		existing.Source = joinUnique(existing.Source, entry.Source)
+		existing.Profile = joinUnique(existing.Profile, entry.Profile)
+	}
+
+	return merged
+}
+
+func joinUnique(list string, value string) string {
+	values := strings.Split(list, ",")
+	for _, existing := range values {
+		if existing == value {
+			return list
+		}
+	}
+
+	values = append(values, value)
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sourceDBs reads every profile of a source, returning the result of fn by
+// profile.
+func sourceDBs[T any](t *testing.T, source historySource, fn func(db *sql.DB) T) map[string]T {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	results := make(map[string]T)
+	if err := source.Each(func(profile string, db *sql.DB) error {
+		results[profile] = fn(db)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return results
+}
+
+func TestChromiumSource(t *testing.T) {
+	source := chromiumSource{dir: "testdata/chromium"}
+	profiles := sourceDBs(t, source, func(db *sql.DB) []HistoryEntry {
+		entries, err := historySearch{Sort: "recent"}.run(db, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return entries
+	})
+
+	entries := profiles["Default"]
+	if len(profiles) != 1 || len(entries) != 2 {
+		t.Fatalf("got %+v, want the 2 urls of the Default profile", profiles)
+	}
+
+	github := entries[0]
+	if github.URL != "https://github.com/pomdtr/arc" || github.VisitCount != 2 || github.DwellSeconds != 40 {
+		t.Errorf("got %+v, want github.com/pomdtr/arc visited twice for 40s", github)
+	}
+
+	if want := time.Date(2024, 5, 15, 11, 0, 0, 0, time.UTC); !github.FirstVisitedAt.Equal(want) {
+		t.Errorf("first visit = %s, want %s", github.FirstVisitedAt, want)
+	}
+
+	if path, _ := source.ProfileFile("Default", "Favicons"); path != "testdata/chromium/Default/Favicons" {
+		t.Errorf("favicons path = %s", path)
+	}
+}
+
+func TestFirefoxViews(t *testing.T) {
+	type place struct {
+		URL           string
+		Title         string
+		TypedCount    int
+		Hidden        bool
+		LastVisitTime int64
+	}
+
+	type visit struct {
+		ID         int
+		URL        int
+		VisitTime  int64
+		FromVisit  int
+		Transition int
+	}
+
+	type places struct {
+		URLs    []place
+		Visits  []visit
+		Entries []HistoryEntry
+	}
+
+	profiles := sourceDBs(t, firefoxSource{dir: "testdata/firefox"}, func(db *sql.DB) places {
+		var result places
+
+		rows, err := db.Query("SELECT url, title, typed_count, hidden, last_visit_time FROM urls ORDER BY id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var p place
+			if err := rows.Scan(&p.URL, &p.Title, &p.TypedCount, &p.Hidden, &p.LastVisitTime); err != nil {
+				t.Fatal(err)
+			}
+			result.URLs = append(result.URLs, p)
+		}
+		rows.Close()
+
+		rows, err = db.Query("SELECT id, url, visit_time, from_visit, transition FROM visits ORDER BY id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var v visit
+			if err := rows.Scan(&v.ID, &v.URL, &v.VisitTime, &v.FromVisit, &v.Transition); err != nil {
+				t.Fatal(err)
+			}
+			result.Visits = append(result.Visits, v)
+		}
+		rows.Close()
+
+		// searches written for Chromium run against the views
+		if result.Entries, err = (historySearch{Sort: "frecency"}).run(db, time.UTC); err != nil {
+			t.Fatal(err)
+		}
+
+		return result
+	})
+
+	result, ok := profiles["abc.default-release"]
+	if len(profiles) != 1 || !ok {
+		t.Fatalf("got profiles %v, want abc.default-release", profiles)
+	}
+
+	// 2024-05-15 12:05 UTC
+	lastVisit := toChromeTime(time.Date(2024, 5, 15, 12, 5, 0, 0, time.UTC))
+	if got := result.URLs[0]; got.URL != "https://github.com/pomdtr/arc" || got.Title != "arc on GitHub" || got.TypedCount != 1 || got.LastVisitTime != lastVisit {
+		t.Errorf("got %+v, want github.com/pomdtr/arc typed once, last visited at %d", got, lastVisit)
+	}
+
+	// places never visited have no title nor visit date
+	if got := result.URLs[1]; got.Title != "" || got.LastVisitTime != 0 {
+		t.Errorf("got %+v, want an empty title and no last visit", got)
+	}
+
+	if got := result.URLs[3]; !got.Hidden {
+		t.Errorf("got %+v, want it hidden", got)
+	}
+
+	// typed, link, bookmark, embed, framed link, reload, download and
+	// permanent redirect
+	var transitions []int
+	for _, v := range result.Visits {
+		transitions = append(transitions, v.Transition)
+	}
+
+	if want := []int{1, 0, 2, 3, 4, 8, 0, 0}; !reflect.DeepEqual(transitions, want) {
+		t.Errorf("transitions = %v, want %v", transitions, want)
+	}
+
+	if got := result.Visits[1]; got.URL != 1 || got.FromVisit != 1 || !chromeTime(got.VisitTime).Equal(time.Date(2024, 5, 15, 12, 5, 0, 0, time.UTC)) {
+		t.Errorf("got %+v, want a visit of url 1 from visit 1 at 12:05", got)
+	}
+
+	if len(result.Entries) != 4 {
+		t.Errorf("got %d entries, want 4", len(result.Entries))
+	}
+}
+
+func TestDedupeHistory(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	entries := []HistoryEntry{
+		{URL: "https://github.com/", Title: "GitHub", VisitCount: 3, TypedCount: 1, FirstVisitedAt: day(2), LastVisitedAt: day(10), DwellSeconds: 5, Score: 1, Hidden: true, Source: "arc", Profile: "Default"},
+		{URL: "https://example.com/", Title: "Example", VisitCount: 1, LastVisitedAt: day(3), Source: "arc", Profile: "Default"},
+		{URL: "https://github.com/", Title: "GitHub · Build software", VisitCount: 2, TypedCount: 2, FirstVisitedAt: day(1), LastVisitedAt: day(12), DwellSeconds: 3, Score: 4, Icon: "/icons/gh.png", Source: "chrome", Profile: "Profile 1"},
+		{URL: "https://github.com/", Title: "Old title", VisitCount: 1, LastVisitedAt: day(5), Score: 2, Hidden: true, Icon: "/icons/other.png", Source: "arc", Profile: "Default"},
+	}
+
+	want := []HistoryEntry{
+		{URL: "https://github.com/", Title: "GitHub · Build software", VisitCount: 6, TypedCount: 3, FirstVisitedAt: day(1), LastVisitedAt: day(12), DwellSeconds: 8, Score: 4, Icon: "/icons/gh.png", Source: "arc,chrome", Profile: "Default,Profile 1"},
+		{URL: "https://example.com/", Title: "Example", VisitCount: 1, LastVisitedAt: day(3), Source: "arc", Profile: "Default"},
+	}
+
+	if got := dedupeHistory(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("dedupeHistory() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestJoinUnique(t *testing.T) {
+	for _, tt := range []struct {
+		list, value, want string
+	}{
+		{"chrome", "arc", "arc,chrome"},
+		{"arc,chrome", "chrome", "arc,chrome"},
+		{"arc,firefox", "chrome", "arc,chrome,firefox"},
+	} {
+		if got := joinUnique(tt.list, tt.value); got != tt.want {
+			t.Errorf("joinUnique(%q, %q) = %q, want %q", tt.list, tt.value, got, tt.want)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	_ "embed"
 
@@ -22,6 +23,7 @@ func NewCmdSpace() *cobra.Command {
 
 	cmd.AddCommand(NewCmdSpaceFocus())
 	cmd.AddCommand(NewCmdSpaceList())
+	cmd.AddCommand(NewCmdSpaceTabs())
 	return cmd
 }
 
@@ -67,16 +69,11 @@ func NewCmdSpaceList() *cobra.Command {
 		Use:   "list",
 		Short: "List spaces",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			output, err := runApplescript(listSpacesScript)
+			spaces, err := listSpaces()
 			if err != nil {
 				return err
 			}
 
-			var spaces []Space
-			if err := json.Unmarshal(output, &spaces); err != nil {
-				return err
-			}
-
 			if flags.Json {
 				encoder := json.NewEncoder(os.Stdout)
 				encoder.SetIndent("", "  ")
@@ -110,3 +107,76 @@ func NewCmdSpaceList() *cobra.Command {
 	cmd.Flags().BoolVar(&flags.Json, "json", false, "output as json")
 	return cmd
 }
+
+func listSpaces() ([]Space, error) {
+	output, err := runApplescript(listSpacesScript)
+	if err != nil {
+		return nil, err
+	}
+
+	var spaces []Space
+	if err := json.Unmarshal(output, &spaces); err != nil {
+		return nil, err
+	}
+
+	return spaces, nil
+}
+
+// resolveSpace finds a space of the front window by id or title.
+func resolveSpace(arg string) (Space, error) {
+	spaces, err := listSpaces()
+	if err != nil {
+		return Space{}, err
+	}
+
+	if id, err := strconv.Atoi(arg); err == nil {
+		for _, space := range spaces {
+			if space.ID == id {
+				return space, nil
+			}
+		}
+	}
+
+	for _, space := range spaces {
+		if strings.EqualFold(space.Title, arg) {
+			return space, nil
+		}
+	}
+
+	return Space{}, fmt.Errorf("space not found: %s", arg)
+}
+
+func NewCmdSpaceTabs() *cobra.Command {
+	var flags struct {
+		Json bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "tabs <space>",
+		Short: "List the tabs of a space",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			space, err := resolveSpace(args[0])
+			if err != nil {
+				return err
+			}
+
+			tabs, err := listTabs()
+			if err != nil {
+				return err
+			}
+
+			var spaceTabs []Tab
+			for _, tab := range tabs {
+				if tab.Space != nil && tab.Space.ID == space.ID {
+					spaceTabs = append(spaceTabs, tab)
+				}
+			}
+
+			return printTabs(spaceTabs, flags.Json)
+		},
+	}
+
+	cmd.Flags().BoolVar(&flags.Json, "json", false, "output as json")
+	return cmd
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/cli/go-gh/v2/pkg/tableprinter"
+	sb "github.com/huandu/go-sqlbuilder"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+type statsFlags struct {
+	timeRange
+	tz   string
+	json bool
+}
+
+func (f *statsFlags) register(cmd *cobra.Command) {
+	f.timeRange.register(cmd)
+	cmd.Flags().StringVar(&f.tz, "tz", "Local", "timezone used to group visits")
+	cmd.Flags().BoolVar(&f.json, "json", false, "output as json")
+}
+
+type statsVisit struct {
+	URLID     int64
+	Domain    string
+	VisitedAt time.Time
+	Duration  time.Duration
+}
+
+// loadStatsVisits reads the visits in the range, which every report
+// aggregates in Go.
+func loadStatsVisits(db *sql.DB, flags statsFlags) ([]statsVisit, error) {
+	location, err := loadLocation(flags.tz)
+	if err != nil {
+		return nil, err
+	}
+
+	query := sb.NewSelectBuilder()
+	query.Select("v.url", "u.url", "v.visit_time", "v.visit_duration")
+	query.From("visits v")
+	query.Join("urls u", "u.id = v.url")
+	if err := flags.timeRange.where(query, "v.visit_time"); err != nil {
+		return nil, err
+	}
+
+	sql, args := query.Build()
+	rows, err := db.Query(sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	defer rows.Close()
+
+	domains := make(map[int64]string)
+	var visits []statsVisit
+	for rows.Next() {
+		var visit statsVisit
+		var url string
+		var visitTime, duration int64
+		if err := rows.Scan(&visit.URLID, &url, &visitTime, &duration); err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		domain, ok := domains[visit.URLID]
+		if !ok {
+			domain = urlDomain(url)
+			domains[visit.URLID] = domain
+		}
+
+		visit.Domain = domain
+		visit.VisitedAt = chromeTime(visitTime).In(location)
+		visit.Duration = time.Duration(duration) * time.Microsecond
+		visits = append(visits, visit)
+	}
+
+	return visits, rows.Err()
+}
+
+func newStatsPrinter() (tableprinter.TablePrinter, error) {
+	if !isatty.IsTerminal(os.Stdout.Fd()) {
+		return tableprinter.New(os.Stdout, false, 0), nil
+	}
+
+	w, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return nil, err
+	}
+
+	return tableprinter.New(os.Stdout, true, w), nil
+}
+
+func printStatsJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(v)
+}
+
+func statsBar(value int, max int, width int) string {
+	if max == 0 {
+		return ""
+	}
+
+	return strings.Repeat("█", (value*width+max-1)/max)
+}
+
+func NewCmdHistoryStats() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Analyze browsing activity",
+	}
+
+	cmd.AddCommand(NewCmdHistoryStatsDomains())
+	cmd.AddCommand(NewCmdHistoryStatsDaily())
+	cmd.AddCommand(NewCmdHistoryStatsHeatmap())
+	cmd.AddCommand(NewCmdHistoryStatsSites())
+
+	return cmd
+}
+
+type DomainStats struct {
+	Domain       string  `json:"domain"`
+	Visits       int     `json:"visits"`
+	DwellSeconds float64 `json:"dwellSeconds"`
+}
+
+// rankDomains sums the visits and dwell time of each domain, ranked by
+// visits or dwell.
+func rankDomains(visits []statsVisit, by string, limit int) []DomainStats {
+	byDomain := make(map[string]*DomainStats)
+	for _, visit := range visits {
+		stats, ok := byDomain[visit.Domain]
+		if !ok {
+			stats = &DomainStats{Domain: visit.Domain}
+			byDomain[visit.Domain] = stats
+		}
+
+		stats.Visits++
+		stats.DwellSeconds += visit.Duration.Seconds()
+	}
+
+	var domains []DomainStats
+	for _, stats := range byDomain {
+		domains = append(domains, *stats)
+	}
+
+	sort.Slice(domains, func(i, j int) bool {
+		if by == "dwell" && domains[i].DwellSeconds != domains[j].DwellSeconds {
+			return domains[i].DwellSeconds > domains[j].DwellSeconds
+		}
+		if domains[i].Visits != domains[j].Visits {
+			return domains[i].Visits > domains[j].Visits
+		}
+		return domains[i].Domain < domains[j].Domain
+	})
+
+	if limit > 0 && len(domains) > limit {
+		domains = domains[:limit]
+	}
+
+	return domains
+}
+
+func NewCmdHistoryStatsDomains() *cobra.Command {
+	var flags struct {
+		statsFlags
+		by    string
+		limit int
+	}
+
+	cmd := &cobra.Command{
+		Use:   "domains",
+		Short: "Show the most visited domains",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if flags.by != "visits" && flags.by != "dwell" {
+				return fmt.Errorf("unknown ranking: %s", flags.by)
+			}
+
+			db, cleanup, err := openHistory()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			visits, err := loadStatsVisits(db, flags.statsFlags)
+			if err != nil {
+				return err
+			}
+
+			domains := rankDomains(visits, flags.by, flags.limit)
+			if flags.json {
+				return printStatsJSON(domains)
+			}
+
+			printer, err := newStatsPrinter()
+			if err != nil {
+				return err
+			}
+
+			printer.AddHeader([]string{"Domain", "Visits", "Dwell"})
+			for _, stats := range domains {
+				printer.AddField(stats.Domain)
+				printer.AddField(strconv.Itoa(stats.Visits))
+				printer.AddField((time.Duration(stats.DwellSeconds) * time.Second).String())
+				printer.EndRow()
+			}
+
+			return printer.Render()
+		},
+	}
+
+	flags.statsFlags.register(cmd)
+	cmd.Flags().StringVar(&flags.by, "by", "visits", "rank domains by visits or dwell")
+	cmd.Flags().IntVarP(&flags.limit, "limit", "l", 20, "limit")
+	return cmd
+}
+
+type DailyStats struct {
+	Date   string `json:"date"`
+	Visits int    `json:"visits"`
+	Sites  int    `json:"sites"`
+}
+
+// dailyStats counts the visits and distinct urls of each day, in the
+// timezone of the visits.
+func dailyStats(visits []statsVisit) []DailyStats {
+	byDay := make(map[string]*DailyStats)
+	sites := make(map[string]map[int64]bool)
+	for _, visit := range visits {
+		date := visit.VisitedAt.Format(time.DateOnly)
+		stats, ok := byDay[date]
+		if !ok {
+			stats = &DailyStats{Date: date}
+			byDay[date] = stats
+			sites[date] = make(map[int64]bool)
+		}
+
+		stats.Visits++
+		sites[date][visit.URLID] = true
+	}
+
+	var days []DailyStats
+	for date, stats := range byDay {
+		stats.Sites = len(sites[date])
+		days = append(days, *stats)
+	}
+
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Date < days[j].Date
+	})
+
+	return days
+}
+
+func NewCmdHistoryStatsDaily() *cobra.Command {
+	var flags statsFlags
+
+	cmd := &cobra.Command{
+		Use:   "daily",
+		Short: "Show visits per day",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			db, cleanup, err := openHistory()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			visits, err := loadStatsVisits(db, flags)
+			if err != nil {
+				return err
+			}
+
+			days := dailyStats(visits)
+			if flags.json {
+				return printStatsJSON(days)
+			}
+
+			printer, err := newStatsPrinter()
+			if err != nil {
+				return err
+			}
+
+			maxVisits := 0
+			for _, stats := range days {
+				maxVisits = max(maxVisits, stats.Visits)
+			}
+
+			printer.AddHeader([]string{"Date", "Visits", "Sites", ""})
+			for _, stats := range days {
+				printer.AddField(stats.Date)
+				printer.AddField(strconv.Itoa(stats.Visits))
+				printer.AddField(strconv.Itoa(stats.Sites))
+				printer.AddField(statsBar(stats.Visits, maxVisits, 40))
+				printer.EndRow()
+			}
+
+			return printer.Render()
+		},
+	}
+
+	flags.register(cmd)
+	return cmd
+}
+
+type HeatmapRow struct {
+	Weekday string  `json:"weekday"`
+	Hours   [24]int `json:"hours"`
+}
+
+var heatmapShades = []struct {
+	Char  string
+	Color lipgloss.Color
+}{
+	{"·", "#3a3a3a"},
+	{"░", "#0e4429"},
+	{"▒", "#006d32"},
+	{"▓", "#26a641"},
+	{"█", "#39d353"},
+}
+
+// heatmapCounts counts visits by weekday, starting on monday, and hour,
+// along with the largest count.
+func heatmapCounts(visits []statsVisit) ([7][24]int, int) {
+	var counts [7][24]int
+	maxCount := 0
+	for _, visit := range visits {
+		day := (int(visit.VisitedAt.Weekday()) + 6) % 7
+		counts[day][visit.VisitedAt.Hour()]++
+		maxCount = max(maxCount, counts[day][visit.VisitedAt.Hour()])
+	}
+
+	return counts, maxCount
+}
+
+func NewCmdHistoryStatsHeatmap() *cobra.Command {
+	var flags statsFlags
+
+	cmd := &cobra.Command{
+		Use:   "heatmap",
+		Short: "Show visits by weekday and hour",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			db, cleanup, err := openHistory()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			visits, err := loadStatsVisits(db, flags)
+			if err != nil {
+				return err
+			}
+
+			counts, maxCount := heatmapCounts(visits)
+
+			if flags.json {
+				var heatmap []HeatmapRow
+				for day := 0; day < 7; day++ {
+					heatmap = append(heatmap, HeatmapRow{
+						Weekday: strings.ToLower(time.Weekday((day + 1) % 7).String()),
+						Hours:   counts[day],
+					})
+				}
+				return printStatsJSON(heatmap)
+			}
+
+			var b strings.Builder
+			b.WriteString("    ")
+			for hour := 0; hour < 24; hour++ {
+				if hour%3 == 0 {
+					fmt.Fprintf(&b, "%-6d", hour)
+				}
+			}
+			b.WriteString("\n")
+
+			for day := 0; day < 7; day++ {
+				b.WriteString(time.Weekday((day + 1) % 7).String()[:3] + " ")
+				for hour := 0; hour < 24; hour++ {
+					level := 0
+					if maxCount > 0 && counts[day][hour] > 0 {
+						level = 1 + (counts[day][hour]*(len(heatmapShades)-1)-1)/maxCount
+					}
+
+					shade := heatmapShades[level]
+					b.WriteString(lipgloss.NewStyle().Foreground(shade.Color).Render(strings.Repeat(shade.Char, 2)))
+				}
+				b.WriteString("\n")
+			}
+
+			_, err = fmt.Print(b.String())
+			return err
+		},
+	}
+
+	flags.register(cmd)
+	return cmd
+}
+
+type SiteStats struct {
+	Domain         string    `json:"domain"`
+	Status         string    `json:"status"`
+	FirstVisitedAt time.Time `json:"firstVisitedAt"`
+	Visits         int       `json:"visits"`
+}
+
+// loadFirstVisits returns the first visit ever of every domain, which has to
+// be looked up outside of the range.
+func loadFirstVisits(db *sql.DB, tz string) (map[string]time.Time, error) {
+	location, err := loadLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT u.url, MIN(v.visit_time) FROM visits v JOIN urls u ON u.id = v.url GROUP BY v.url")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	defer rows.Close()
+
+	firstVisits := make(map[string]time.Time)
+	for rows.Next() {
+		var url string
+		var visitTime int64
+		if err := rows.Scan(&url, &visitTime); err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		domain := urlDomain(url)
+		visitedAt := chromeTime(visitTime).In(location)
+		if first, ok := firstVisits[domain]; !ok || visitedAt.Before(first) {
+			firstVisits[domain] = visitedAt
+		}
+	}
+
+	return firstVisits, rows.Err()
+}
+
+// siteStats tells apart the domains first visited since a time from the
+// returning ones, new domains first.
+func siteStats(visits []statsVisit, firstVisits map[string]time.Time, since time.Time) []SiteStats {
+	byDomain := make(map[string]*SiteStats)
+	for _, visit := range visits {
+		stats, ok := byDomain[visit.Domain]
+		if !ok {
+			stats = &SiteStats{
+				Domain:         visit.Domain,
+				Status:         "returning",
+				FirstVisitedAt: firstVisits[visit.Domain],
+			}
+			if !stats.FirstVisitedAt.Before(since) {
+				stats.Status = "new"
+			}
+			byDomain[visit.Domain] = stats
+		}
+
+		stats.Visits++
+	}
+
+	var sites []SiteStats
+	for _, stats := range byDomain {
+		sites = append(sites, *stats)
+	}
+
+	sort.Slice(sites, func(i, j int) bool {
+		if sites[i].Status != sites[j].Status {
+			return sites[i].Status == "new"
+		}
+		if sites[i].Visits != sites[j].Visits {
+			return sites[i].Visits > sites[j].Visits
+		}
+		return sites[i].Domain < sites[j].Domain
+	})
+
+	return sites
+}
+
+func NewCmdHistoryStatsSites() *cobra.Command {
+	var flags statsFlags
+
+	cmd := &cobra.Command{
+		Use:   "sites",
+		Short: "Show new and returning sites",
+		Long: `Show new and returning sites.
+
+A site is new when its first visit ever falls in the range, which defaults
+to the last 7 days.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			// every site would be new without a start
+			if flags.Since == "" && flags.On == "" {
+				flags.Since = "7d"
+			}
+
+			since, _, err := flags.bounds()
+			if err != nil {
+				return err
+			}
+
+			db, cleanup, err := openHistory()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			visits, err := loadStatsVisits(db, flags)
+			if err != nil {
+				return err
+			}
+
+			firstVisits, err := loadFirstVisits(db, flags.tz)
+			if err != nil {
+				return err
+			}
+
+			sites := siteStats(visits, firstVisits, since)
+			if flags.json {
+				return printStatsJSON(sites)
+			}
+
+			printer, err := newStatsPrinter()
+			if err != nil {
+				return err
+			}
+
+			printer.AddHeader([]string{"Domain", "Status", "FirstVisitedAt", "Visits"})
+			for _, stats := range sites {
+				printer.AddField(stats.Domain)
+				printer.AddField(stats.Status)
+				printer.AddField(stats.FirstVisitedAt.Format(time.DateTime))
+				printer.AddField(strconv.Itoa(stats.Visits))
+				printer.EndRow()
+			}
+
+			return printer.Render()
+		},
+	}
+
+	flags.register(cmd)
+	return cmd
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// openStatsFixture creates a History database with visits to two domains
+// around the first days of June 2024.
+func openStatsFixture(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "History"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(historySchema); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{
+		"INSERT INTO urls (id, url, title, last_visit_time) VALUES (1, 'https://github.com/a', 'A', 0)",
+		"INSERT INTO urls (id, url, title, last_visit_time) VALUES (2, 'https://github.com/b', 'B', 0)",
+		"INSERT INTO urls (id, url, title, last_visit_time) VALUES (3, 'https://example.com/', 'Example', 0)",
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, visit := range []struct {
+		URL       int
+		VisitedAt time.Time
+		Duration  time.Duration
+	}{
+		{1, time.Date(2024, 6, 3, 9, 10, 0, 0, time.UTC), time.Minute},
+		{2, time.Date(2024, 6, 3, 9, 40, 0, 0, time.UTC), 30 * time.Second},
+		{1, time.Date(2024, 6, 4, 23, 30, 0, 0, time.UTC), 10 * time.Second},
+		{3, time.Date(2024, 6, 4, 10, 0, 0, 0, time.UTC), 10 * time.Minute},
+		{3, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), 0},
+	} {
+		if _, err := db.Exec("INSERT INTO visits (url, visit_time, visit_duration) VALUES (?, ?, ?)", visit.URL, toChromeTime(visit.VisitedAt), visit.Duration.Microseconds()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return db
+}
+
+func TestHistoryStats(t *testing.T) {
+	db := openStatsFixture(t)
+
+	flags := statsFlags{timeRange: timeRange{Since: "2024-06-01T00:00:00Z"}, tz: "UTC"}
+	visits, err := loadStatsVisits(db, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(visits) != 4 {
+		t.Fatalf("got %d visits in range, want 4", len(visits))
+	}
+
+	t.Run("domains", func(t *testing.T) {
+		if got, want := rankDomains(visits, "visits", 0), []DomainStats{
+			{Domain: "github.com", Visits: 3, DwellSeconds: 100},
+			{Domain: "example.com", Visits: 1, DwellSeconds: 600},
+		}; !reflect.DeepEqual(got, want) {
+			t.Errorf("by visits = %+v, want %+v", got, want)
+		}
+
+		if got, want := rankDomains(visits, "dwell", 1), []DomainStats{
+			{Domain: "example.com", Visits: 1, DwellSeconds: 600},
+		}; !reflect.DeepEqual(got, want) {
+			t.Errorf("by dwell = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("daily", func(t *testing.T) {
+		if got, want := dailyStats(visits), []DailyStats{
+			{Date: "2024-06-03", Visits: 2, Sites: 2},
+			{Date: "2024-06-04", Visits: 2, Sites: 2},
+		}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("heatmap", func(t *testing.T) {
+		counts, maxCount := heatmapCounts(visits)
+
+		// june 3rd 2024 is a monday, the first row
+		var want [7][24]int
+		want[0][9] = 2
+		want[1][10] = 1
+		want[1][23] = 1
+		if counts != want || maxCount != 2 {
+			t.Errorf("got %v with max %d, want %v with max 2", counts, maxCount, want)
+		}
+	})
+
+	t.Run("sites", func(t *testing.T) {
+		firstVisits, err := loadFirstVisits(db, "UTC")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// example.com was visited before the range
+		since := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+		got := siteStats(visits, firstVisits, since)
+		want := []SiteStats{
+			{Domain: "github.com", Status: "new", FirstVisitedAt: time.Date(2024, 6, 3, 9, 10, 0, 0, time.UTC), Visits: 3},
+			{Domain: "example.com", Status: "returning", FirstVisitedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), Visits: 1},
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("got %+v, want %+v", got, want)
+		}
+
+		for i := range got {
+			if got[i].Domain != want[i].Domain || got[i].Status != want[i].Status || !got[i].FirstVisitedAt.Equal(want[i].FirstVisitedAt) || got[i].Visits != want[i].Visits {
+				t.Errorf("site %d = %+v, want %+v", i, got[i], want[i])
+			}
+		}
+	})
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// archiveMigrations upgrade the schema of the history archive, the schema
+// version being the number of migrations applied. The urls and visits tables
+// mirror the columns of the History database so that the same queries run
+// against both. Chromium hands out the ids of deleted visits again, so
+// archived visits get ids of their own and keep their History id as
+// source_id.
+var archiveMigrations = []string{
+	`CREATE TABLE urls (
+		id INTEGER PRIMARY KEY,
+		url TEXT NOT NULL UNIQUE,
+		title TEXT NOT NULL DEFAULT '',
+		visit_count INTEGER NOT NULL DEFAULT 0,
+		typed_count INTEGER NOT NULL DEFAULT 0,
+		last_visit_time INTEGER NOT NULL DEFAULT 0,
+		hidden INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE visits (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		source_id INTEGER NOT NULL DEFAULT 0,
+		url INTEGER NOT NULL REFERENCES urls(id),
+		visit_time INTEGER NOT NULL,
+		from_visit INTEGER NOT NULL DEFAULT 0,
+		transition INTEGER NOT NULL DEFAULT 0,
+		visit_duration INTEGER NOT NULL DEFAULT 0,
+		opener_visit INTEGER NOT NULL DEFAULT 0,
+		UNIQUE (url, visit_time)
+	);
+	CREATE INDEX visits_url_index ON visits (url);
+	CREATE INDEX visits_time_index ON visits (visit_time);
+	CREATE INDEX visits_source_index ON visits (source_id);
+	CREATE TABLE sync (
+		key TEXT PRIMARY KEY,
+		value INTEGER NOT NULL
+	);`,
+}
+
+func migrateArchive(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read archive version: %w", err)
+	}
+
+	if version > len(archiveMigrations) {
+		return fmt.Errorf("history archive version %d is newer than this version of arc", version)
+	}
+
+	for i := version; i < len(archiveMigrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to migrate archive: %w", err)
+		}
+
+		if _, err := tx.Exec(archiveMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to migrate archive to version %d: %w", i+1, err)
+		}
+
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to migrate archive to version %d: %w", i+1, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to migrate archive: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// openHistoryArchive opens the synced history of a profile.
+func openHistoryArchive(profile Profile) (*sql.DB, func(), error) {
+	path, err := historyArchivePath(profile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, fmt.Errorf("failed to open history archive, run arc history sync first: %w", err)
+	}
+
+	dsn := url.URL{Scheme: "file", Path: path, RawQuery: "mode=ro"}
+	db, err := sql.Open("sqlite", dsn.String())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open db: %w", err)
+	}
+
+	return db, func() {
+		db.Close()
+	}, nil
+}
+
+type syncResult struct {
+	URLs   int64
+	Visits int64
+}
+
+// syncHistory copies the urls and visits added to the History database of a
+// profile since the last sync into its archive.
+func syncHistory(profile Profile) (syncResult, error) {
+	historyPath, err := profile.Path("History")
+	if err != nil {
+		return syncResult{}, err
+	}
+
+	archivePath, err := historyArchivePath(profile)
+	if err != nil {
+		return syncResult{}, err
+	}
+
+	return syncHistoryFile(historyPath, archivePath)
+}
+
+func syncHistoryFile(historyPath string, archivePath string) (syncResult, error) {
+	var result syncResult
+
+	source, cleanup, err := snapshotSource(historyPath)
+	if err != nil {
+		return result, err
+	}
+	defer cleanup()
+
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0700); err != nil {
+		return result, fmt.Errorf("failed to create archive dir: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", archivePath)
+	if err != nil {
+		return result, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer db.Close()
+
+	// the source is attached to a single connection
+	db.SetMaxOpenConns(1)
+
+	if err := migrateArchive(db); err != nil {
+		return result, err
+	}
+
+	if _, err := db.Exec("ATTACH DATABASE ? AS source", source); err != nil {
+		return result, fmt.Errorf("failed to attach history: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to sync: %w", err)
+	}
+	defer tx.Rollback()
+
+	// visits are tracked by time rather than id, as Chromium hands out the
+	// ids of deleted visits again
+	var lastVisitTime, lastURLTime, lastArchiveID int64
+	tx.QueryRow("SELECT value FROM sync WHERE key = 'last_visit_time'").Scan(&lastVisitTime)
+	tx.QueryRow("SELECT value FROM sync WHERE key = 'last_url_time'").Scan(&lastURLTime)
+	if err := tx.QueryRow("SELECT COALESCE(MAX(id), 0) FROM visits").Scan(&lastArchiveID); err != nil {
+		return result, fmt.Errorf("failed to query: %w", err)
+	}
+
+	urls, err := tx.Exec(`INSERT INTO urls (url, title, visit_count, typed_count, last_visit_time, hidden)
+		SELECT url, title, visit_count, typed_count, last_visit_time, hidden FROM source.urls
+		WHERE last_visit_time > ? OR id IN (SELECT url FROM source.visits WHERE visit_time > ?)
+		ON CONFLICT (url) DO UPDATE SET
+			title = excluded.title,
+			visit_count = MAX(urls.visit_count, excluded.visit_count),
+			typed_count = MAX(urls.typed_count, excluded.typed_count),
+			last_visit_time = MAX(urls.last_visit_time, excluded.last_visit_time),
+			hidden = excluded.hidden`, lastURLTime, lastVisitTime)
+	if err != nil {
+		return result, fmt.Errorf("failed to sync urls: %w", err)
+	}
+	result.URLs, _ = urls.RowsAffected()
+
+	opener := "0"
+	if _, err := tx.Exec("SELECT opener_visit FROM source.visits LIMIT 0"); err == nil {
+		opener = "COALESCE(v.opener_visit, 0)"
+	}
+
+	// referrers are copied as their History ids first, and mapped to
+	// archive ids once all new visits are in
+	visits, err := tx.Exec(fmt.Sprintf(`INSERT OR IGNORE INTO visits (source_id, url, visit_time, from_visit, transition, visit_duration, opener_visit)
+		SELECT v.id, a.id, v.visit_time, COALESCE(v.from_visit, 0), v.transition, v.visit_duration, %s
+		FROM source.visits v
+		JOIN source.urls u ON u.id = v.url
+		JOIN urls a ON a.url = u.url
+		WHERE v.visit_time > ?
+		ORDER BY v.visit_time, v.id`, opener), lastVisitTime)
+	if err != nil {
+		return result, fmt.Errorf("failed to sync visits: %w", err)
+	}
+	result.Visits, _ = visits.RowsAffected()
+
+	// a referrer is the latest visit archived with its History id that
+	// happened before the visit, as ids may have been reused since
+	if _, err := tx.Exec(`UPDATE visits SET
+		from_visit = COALESCE((SELECT MAX(r.id) FROM visits r
+			WHERE r.source_id = visits.from_visit AND r.visit_time <= visits.visit_time AND r.id != visits.id), 0),
+		opener_visit = COALESCE((SELECT MAX(r.id) FROM visits r
+			WHERE r.source_id = visits.opener_visit AND r.visit_time <= visits.visit_time AND r.id != visits.id), 0)
+		WHERE id > ?`, lastArchiveID); err != nil {
+		return result, fmt.Errorf("failed to sync visits: %w", err)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO sync (key, value) VALUES
+		('last_visit_time', (SELECT MAX(?, COALESCE(MAX(visit_time), 0)) FROM source.visits)),
+		('last_url_time', (SELECT MAX(?, COALESCE(MAX(last_visit_time), 0)) FROM source.urls))
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value`, lastVisitTime, lastURLTime); err != nil {
+		return result, fmt.Errorf("failed to save sync state: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to sync: %w", err)
+	}
+
+	return result, nil
+}
+
+func NewCmdHistorySync() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Copy new history into a permanent archive",
+		Long: `Copy new history into a permanent archive.
+
+Arc expires visits older than about 90 days. Running this command on a
+schedule keeps them in an archive, which can be searched with
+arc history --archive.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profiles, err := selectedProfiles()
+			if err != nil {
+				return err
+			}
+
+			for _, profile := range profiles {
+				result, err := syncHistory(profile)
+				if errors.Is(err, fs.ErrNotExist) && len(profiles) > 1 {
+					continue
+				}
+
+				if err != nil {
+					return fmt.Errorf("failed to sync %s: %w", profile.Dir, err)
+				}
+
+				cmd.Printf("%s: %d urls, %d visits\n", profile.Dir, result.URLs, result.Visits)
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
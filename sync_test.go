@@ -0,0 +1,86 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestSyncHistoryReusedVisitIDs(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	historyPath := filepath.Join(dir, "History")
+	archivePath := filepath.Join(dir, "archive", "Default.sqlite")
+	writeHistoryFixture(t, historyPath, 3, "WAL")
+
+	result, err := syncHistoryFile(historyPath, archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != (syncResult{URLs: 3, Visits: 6}) {
+		t.Errorf("first sync = %+v, want 3 urls, 6 visits", result)
+	}
+
+	history, err := sql.Open("sqlite", historyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+
+	// visit 3 is deleted and its id handed out again to a visit of a new url,
+	// opened from visit 4
+	for _, query := range []string{
+		"DELETE FROM visits WHERE id = 3",
+		"INSERT INTO urls (id, url, title, last_visit_time) VALUES (4, 'https://new.example.com/', 'New', 13348540900000000)",
+		"INSERT INTO visits (id, url, visit_time, from_visit, opener_visit) VALUES (3, 4, 13348540900000000, 4, 4)",
+	} {
+		if _, err := history.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err = syncHistoryFile(historyPath, archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != (syncResult{URLs: 1, Visits: 1}) {
+		t.Errorf("second sync = %+v, want 1 url, 1 visit", result)
+	}
+
+	archive, err := sql.Open("sqlite", archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+
+	var visits, reused int
+	archive.QueryRow("SELECT COUNT(*), SUM(source_id = 3) FROM visits").Scan(&visits, &reused)
+	if visits != 7 || reused != 2 {
+		t.Errorf("got %d visits, %d with source id 3, want 7 and 2", visits, reused)
+	}
+
+	var url string
+	var fromVisit, openerVisit, referrer int
+	if err := archive.QueryRow(`SELECT u.url, v.from_visit, v.opener_visit FROM visits v
+		JOIN urls u ON u.id = v.url
+		WHERE v.id = (SELECT MAX(id) FROM visits)`).Scan(&url, &fromVisit, &openerVisit); err != nil {
+		t.Fatal(err)
+	}
+
+	archive.QueryRow("SELECT id FROM visits WHERE source_id = 4").Scan(&referrer)
+	if url != "https://new.example.com/" || fromVisit != referrer || openerVisit != referrer {
+		t.Errorf("new visit = %s from %d opened by %d, want https://new.example.com/ from and opened by %d", url, fromVisit, openerVisit, referrer)
+	}
+
+	result, err = syncHistoryFile(historyPath, archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != (syncResult{}) {
+		t.Errorf("third sync = %+v, want nothing new", result)
+	}
+}
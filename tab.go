@@ -22,6 +22,8 @@ type Tab struct {
 	URL      string `json:"url"`
 	ID       string `json:"id"`
 	Location string `json:"location"`
+	Space    *Space `json:"space"`
+	Icon     string `json:"icon,omitempty"`
 }
 
 type State string
@@ -59,6 +61,8 @@ func NewCmdTab() *cobra.Command {
 	cmd.AddCommand(NewCmdTabClose())
 	cmd.AddCommand(NewCmdTabReload())
 	cmd.AddCommand(NewCmdTabExecute())
+	cmd.AddCommand(NewCmdTabClosed())
+	cmd.AddCommand(NewCmdTabReopen())
 
 	return cmd
 }
@@ -127,30 +131,18 @@ func NewCmdTabCreate() *cobra.Command {
 		Short:   `Create a new tab.`,
 		Aliases: []string{"open", "new"},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			var osascript string
 			if flags.LittleArc {
-				osascript = fmt.Sprintf(`tell application "Arc" to make new tab with properties {URL:"%s"}`, args[0])
-			} else if cmd.Flags().Changed("space") {
-				osascript = fmt.Sprintf(`tell application "Arc"
-				    tell space %d
-					    make new tab with properties {URL:"%s"}
-					end tell
-					activate
-			    end tell`, flags.Space, args[0])
-			} else {
-				osascript = fmt.Sprintf(`tell application "Arc"
-					tell front window
-					  make new tab with properties {URL:"%s"}
-					end tell
-					activate
-				end tell`, args[0])
+				_, err := runApplescript(`on run argv
+					tell application "Arc" to make new tab with properties {URL:item 1 of argv}
+				end run`, args[0])
+				return err
 			}
 
-			if _, err := runApplescript(osascript); err != nil {
-				return err
+			if cmd.Flags().Changed("space") {
+				return createTabInSpace(args[0], flags.Space)
 			}
 
-			return nil
+			return createTab(args[0])
 		},
 	}
 
@@ -159,6 +151,36 @@ func NewCmdTabCreate() *cobra.Command {
 	return cmd
 }
 
+func createTab(url string) error {
+	if _, err := runApplescript(`on run argv
+		tell application "Arc"
+			tell front window
+				make new tab with properties {URL:item 1 of argv}
+			end tell
+			activate
+		end tell
+	end run`, url); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func createTabInSpace(url string, space int) error {
+	if _, err := runApplescript(`on run argv
+		tell application "Arc"
+			tell space ((item 2 of argv) as integer)
+				make new tab with properties {URL:item 1 of argv}
+			end tell
+			activate
+		end tell
+	end run`, url, strconv.Itoa(space)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func NewCmdTabFocus() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "focus <tab-id>",
@@ -198,6 +220,7 @@ func NewCmdTabList() *cobra.Command {
 		Pinned   bool
 		Favorite bool
 		Unpinned bool
+		IconsDir string
 		Json     bool
 	}
 
@@ -206,16 +229,11 @@ func NewCmdTabList() *cobra.Command {
 		Aliases: []string{"ls"},
 		Short:   `List tabs`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			output, err := runApplescript(listTabsScript)
+			tabs, err := listTabs()
 			if err != nil {
 				return err
 			}
 
-			var tabs []Tab
-			if err := json.Unmarshal(output, &tabs); err != nil {
-				return err
-			}
-
 			var filteredTabs []Tab
 			if !flags.Pinned && !flags.Unpinned && !flags.Favorite {
 				filteredTabs = tabs
@@ -235,63 +253,167 @@ func NewCmdTabList() *cobra.Command {
 				}
 			}
 
-			sort.SliceStable(filteredTabs, func(i, j int) bool {
-				if filteredTabs[i].State() == filteredTabs[j].State() {
-					return filteredTabs[i].ID < filteredTabs[j].ID
+			if flags.IconsDir != "" {
+				if err := resolveTabIcons(filteredTabs, flags.IconsDir); err != nil {
+					return err
 				}
+			}
 
-				if filteredTabs[i].State() == TabStateFavorite {
-					return true
-				}
+			return printTabs(filteredTabs, flags.Json)
+		},
+	}
 
-				if filteredTabs[j].State() == TabStateFavorite {
-					return false
-				}
+	cmd.Flags().BoolVar(&flags.Json, "json", false, "output as json")
+	cmd.Flags().BoolVar(&flags.Pinned, "pinned", false, "only show pinned tabs")
+	cmd.Flags().BoolVar(&flags.Unpinned, "unpinned", false, "only show unpinned tabs")
+	cmd.Flags().BoolVar(&flags.Favorite, "favorite", false, "only show favorite tabs")
+	cmd.Flags().StringVar(&flags.IconsDir, "icons-dir", "", "write favicons to this dir and include their paths in the json output")
+	return cmd
+}
 
-				if filteredTabs[i].State() == TabStatePinned {
-					return true
-				}
+// resolveTabIcons sets the icon of each tab from the favicons of the selected
+// profiles.
+func resolveTabIcons(tabs []Tab, iconsDir string) error {
+	urls := make([]string, len(tabs))
+	for i, tab := range tabs {
+		urls[i] = tab.URL
+	}
 
-				return false
-			})
+	icons, err := resolveIcons(urls, iconsDir)
+	if err != nil {
+		return err
+	}
 
-			if flags.Json {
-				encoder := json.NewEncoder(os.Stdout)
-				encoder.SetIndent("", "  ")
-				encoder.SetEscapeHTML(false)
-				return encoder.Encode(filteredTabs)
-			}
+	for i := range tabs {
+		tabs[i].Icon = icons[i]
+	}
 
-			var printer tableprinter.TablePrinter
-			if !isatty.IsTerminal(os.Stdout.Fd()) {
-				printer = tableprinter.New(os.Stdout, false, 0)
-			} else {
-				w, _, err := term.GetSize(int(os.Stdout.Fd()))
-				if err != nil {
-					return err
-				}
+	return nil
+}
+
+// resolveIcons writes the icons of urls to iconsDir from the favicons of the
+// selected profiles, the first profile having one winning, and returns their
+// paths. Urls without an icon get an empty path.
+func resolveIcons(urls []string, iconsDir string) ([]string, error) {
+	profiles, err := selectedProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	icons, err := newIconCache(iconsDir)
+	if err != nil {
+		return nil, err
+	}
+	defer icons.Close()
 
-				printer = tableprinter.New(os.Stdout, true, w)
+	paths := make([]string, len(urls))
+	for i, pageURL := range urls {
+		for _, profile := range profiles {
+			faviconsPath, err := profile.Path("Favicons")
+			if err != nil {
+				return nil, err
 			}
 
-			printer.AddHeader([]string{"ID", "State", "Title", "URL"})
-			for _, tab := range filteredTabs {
-				printer.AddField(tab.ID)
-				printer.AddField(string(tab.State()))
-				printer.AddField(tab.Title)
-				printer.AddField(tab.URL)
-				printer.EndRow()
+			if paths[i], err = icons.Path(faviconsPath, pageURL); err != nil {
+				return nil, err
 			}
 
-			return printer.Render()
-		},
+			if paths[i] != "" {
+				break
+			}
+		}
 	}
 
-	cmd.Flags().BoolVar(&flags.Json, "json", false, "output as json")
-	cmd.Flags().BoolVar(&flags.Pinned, "pinned", false, "only show pinned tabs")
-	cmd.Flags().BoolVar(&flags.Unpinned, "unpinned", false, "only show unpinned tabs")
-	cmd.Flags().BoolVar(&flags.Favorite, "favorite", false, "only show favorite tabs")
-	return cmd
+	return paths, nil
+}
+
+// listTabs returns the tabs of the front window, sorted by state. Tabs
+// belonging to a space carry its id and title; favorites are shared across
+// spaces and have none.
+func listTabs() ([]Tab, error) {
+	output, err := runApplescript(listTabsScript)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []Tab
+	if err := json.Unmarshal(output, &items); err != nil {
+		return nil, err
+	}
+
+	// the script lists favorites first, so that they keep no space when they
+	// show up again in the tabs of a space
+	seen := make(map[string]bool)
+	var tabs []Tab
+	for _, tab := range items {
+		if seen[tab.ID] {
+			continue
+		}
+
+		seen[tab.ID] = true
+		tabs = append(tabs, tab)
+	}
+
+	sort.SliceStable(tabs, func(i, j int) bool {
+		if tabs[i].State() == tabs[j].State() {
+			return tabs[i].ID < tabs[j].ID
+		}
+
+		if tabs[i].State() == TabStateFavorite {
+			return true
+		}
+
+		if tabs[j].State() == TabStateFavorite {
+			return false
+		}
+
+		if tabs[i].State() == TabStatePinned {
+			return true
+		}
+
+		return false
+	})
+
+	return tabs, nil
+}
+
+func printTabs(tabs []Tab, asJson bool) error {
+	if asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(tabs)
+	}
+
+	var printer tableprinter.TablePrinter
+	if !isatty.IsTerminal(os.Stdout.Fd()) {
+		printer = tableprinter.New(os.Stdout, false, 0)
+	} else {
+		w, _, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			return err
+		}
+
+		printer = tableprinter.New(os.Stdout, true, w)
+	}
+
+	// the space is appended so that scripts reading the columns by position
+	// keep working
+	printer.AddHeader([]string{"ID", "State", "Title", "URL", "Space"})
+	for _, tab := range tabs {
+		printer.AddField(tab.ID)
+		printer.AddField(string(tab.State()))
+		printer.AddField(tab.Title)
+		printer.AddField(tab.URL)
+		if tab.Space != nil {
+			printer.AddField(tab.Space.Title)
+		} else {
+			printer.AddField("")
+		}
+		printer.EndRow()
+	}
+
+	return printer.Render()
 }
 
 func NewCmdTabClose() *cobra.Command {
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type TrailNode struct {
+	Visit
+	Relation string       `json:"relation,omitempty"`
+	Target   bool         `json:"target,omitempty"`
+	Children []*TrailNode `json:"children,omitempty"`
+	opener   int64
+}
+
+type trailWalker struct {
+	db     *sql.DB
+	opener bool
+	seen   map[int64]bool
+}
+
+func newTrailWalker(db *sql.DB) *trailWalker {
+	// opener_visit is missing from older History databases
+	_, err := db.Exec("SELECT opener_visit FROM visits LIMIT 0")
+	return &trailWalker{db: db, opener: err == nil, seen: make(map[int64]bool)}
+}
+
+func (w *trailWalker) selectVisits(where string) string {
+	opener := "0"
+	if w.opener {
+		opener = "COALESCE(v.opener_visit, 0)"
+	}
+
+	return fmt.Sprintf(`SELECT v.id, u.url, u.title, v.visit_time, v.transition, COALESCE(v.from_visit, 0), %s
+		FROM visits v JOIN urls u ON u.id = v.url
+		WHERE %s ORDER BY v.visit_time`, opener, where)
+}
+
+func (w *trailWalker) query(where string, args ...any) ([]*TrailNode, error) {
+	rows, err := w.db.Query(w.selectVisits(where), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	defer rows.Close()
+
+	var nodes []*TrailNode
+	for rows.Next() {
+		var node TrailNode
+		var visitTime, transition int64
+		if err := rows.Scan(&node.ID, &node.URL, &node.Title, &visitTime, &transition, &node.FromVisit, &node.opener); err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		node.VisitedAt = chromeTime(visitTime)
+		node.Transition = transitionType(transition)
+		node.Qualifiers = transitionQualifierNames(transition)
+		nodes = append(nodes, &node)
+	}
+
+	return nodes, rows.Err()
+}
+
+// find resolves a visit id, or the latest visit of a url.
+func (w *trailWalker) find(arg string) (*TrailNode, error) {
+	var nodes []*TrailNode
+	var err error
+	if id, convErr := strconv.ParseInt(arg, 10, 64); convErr == nil {
+		nodes, err = w.query("v.id = ?", id)
+	} else {
+		nodes, err = w.query("v.id = (SELECT v.id FROM visits v JOIN urls u ON u.id = v.url WHERE u.url = ? ORDER BY v.visit_time DESC LIMIT 1)", arg)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no visit found for %s", arg)
+	}
+
+	w.seen[nodes[0].ID] = true
+	return nodes[0], nil
+}
+
+// ancestors walks back from a visit through referrers, or openers when a
+// visit has no referrer, returning the chain from the oldest ancestor down to
+// the visit itself.
+func (w *trailWalker) ancestors(node *TrailNode, limit int) ([]*TrailNode, error) {
+	chain := []*TrailNode{node}
+	for len(chain) <= limit {
+		current := chain[0]
+		parentID, relation := current.FromVisit, "link"
+		if parentID == 0 {
+			parentID, relation = current.opener, "opener"
+		}
+
+		if parentID == 0 || w.seen[parentID] {
+			break
+		}
+
+		parents, err := w.query("v.id = ?", parentID)
+		if err != nil {
+			return nil, err
+		}
+		if len(parents) == 0 {
+			break
+		}
+
+		w.seen[parentID] = true
+		current.Relation = relation
+		chain = append([]*TrailNode{parents[0]}, chain...)
+	}
+
+	return chain, nil
+}
+
+// descendants attaches the visits reached from a node, up to depth levels.
+func (w *trailWalker) descendants(node *TrailNode, depth int) error {
+	if depth == 0 {
+		return nil
+	}
+
+	where := "v.from_visit = ?"
+	args := []any{node.ID}
+	if w.opener {
+		where = "(v.from_visit = ? OR v.opener_visit = ?)"
+		args = append(args, node.ID)
+	}
+
+	children, err := w.query(where, args...)
+	if err != nil {
+		return err
+	}
+
+	for _, child := range children {
+		if w.seen[child.ID] {
+			continue
+		}
+		w.seen[child.ID] = true
+
+		child.Relation = "link"
+		if child.FromVisit != node.ID {
+			child.Relation = "opener"
+		}
+
+		node.Children = append(node.Children, child)
+		if err := w.descendants(child, depth-1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// buildTrail returns the tree of the visits leading to a page, from its
+// oldest ancestor, and of the visits it led to, up to depth levels.
+func buildTrail(db *sql.DB, arg string, depth int) (*TrailNode, error) {
+	walker := newTrailWalker(db)
+	target, err := walker.find(arg)
+	if err != nil {
+		return nil, err
+	}
+	target.Target = true
+
+	chain, err := walker.ancestors(target, 100)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i+1 < len(chain); i++ {
+		chain[i].Children = []*TrailNode{chain[i+1]}
+	}
+
+	if err := walker.descendants(target, depth); err != nil {
+		return nil, err
+	}
+
+	return chain[0], nil
+}
+
+func walkTrail(root *TrailNode, fn func(node *TrailNode, parent *TrailNode, depth int)) {
+	var walk func(node *TrailNode, parent *TrailNode, depth int)
+	walk = func(node *TrailNode, parent *TrailNode, depth int) {
+		fn(node, parent, depth)
+		for _, child := range node.Children {
+			walk(child, node, depth+1)
+		}
+	}
+
+	walk(root, nil, 0)
+}
+
+func trailLabel(node *TrailNode) string {
+	if node.Title != "" {
+		return node.Title
+	}
+
+	return node.URL
+}
+
+func writeTrailTree(root *TrailNode) {
+	walkTrail(root, func(node *TrailNode, parent *TrailNode, depth int) {
+		marker := ""
+		if node.Target {
+			marker = " *"
+		}
+
+		fmt.Printf("%s%s [%s] %s (%s)%s\n", strings.Repeat("  ", depth), node.VisitedAt.Local().Format(time.DateTime), node.Transition, trailLabel(node), node.URL, marker)
+	})
+}
+
+func writeTrailDot(root *TrailNode) {
+	quote := func(s string) string {
+		return strconv.Quote(s)
+	}
+
+	fmt.Println("digraph trail {")
+	fmt.Println("  node [shape=box];")
+	walkTrail(root, func(node *TrailNode, parent *TrailNode, depth int) {
+		attributes := fmt.Sprintf("label=%s", quote(trailLabel(node)+"\n"+node.URL))
+		if node.Target {
+			attributes += ", style=bold"
+		}
+		fmt.Printf("  v%d [%s];\n", node.ID, attributes)
+
+		if parent != nil {
+			fmt.Printf("  v%d -> v%d [label=%s];\n", parent.ID, node.ID, quote(node.Relation))
+		}
+	})
+	fmt.Println("}")
+}
+
+func writeTrailMermaid(root *TrailNode) {
+	escape := strings.NewReplacer(`"`, "#quot;")
+
+	fmt.Println("graph TD")
+	walkTrail(root, func(node *TrailNode, parent *TrailNode, depth int) {
+		fmt.Printf("  v%d[\"%s\"]\n", node.ID, escape.Replace(trailLabel(node)))
+		if parent != nil {
+			fmt.Printf("  v%d -->|%s| v%d\n", parent.ID, node.Relation, node.ID)
+		}
+		if node.Target {
+			fmt.Printf("  style v%d stroke-width:3px\n", node.ID)
+		}
+	})
+}
+
+func NewCmdHistoryTrail() *cobra.Command {
+	var flags struct {
+		format string
+		depth  int
+	}
+
+	cmd := &cobra.Command{
+		Use:   "trail <url|visit-id>",
+		Short: "Show how a page was reached and where it led",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, cleanup, err := openHistory()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			root, err := buildTrail(db, args[0], flags.depth)
+			if err != nil {
+				return err
+			}
+
+			switch flags.format {
+			case "tree":
+				writeTrailTree(root)
+			case "dot":
+				writeTrailDot(root)
+			case "mermaid":
+				writeTrailMermaid(root)
+			case "json":
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				encoder.SetEscapeHTML(false)
+				return encoder.Encode(root)
+			default:
+				return fmt.Errorf("unknown format: %s", flags.format)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.format, "format", "f", "tree", "output format (tree, dot, mermaid, json)")
+	cmd.Flags().IntVar(&flags.depth, "depth", 5, "how many steps to follow after the page")
+	return cmd
+}
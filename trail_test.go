@@ -0,0 +1,100 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// openTrailFixture creates a History database where a search leads to a
+// result, opened in a new tab, from which two links are followed. Visits 7
+// and 8 refer to each other.
+func openTrailFixture(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "History"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(historySchema); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, url := range []string{"https://google.com/search?q=arc", "https://arc.net/", "https://arc.net/download", "https://github.com/", "https://github.com/pomdtr/arc", "https://example.com/a", "https://example.com/b"} {
+		if _, err := db.Exec("INSERT INTO urls (id, url, title, last_visit_time) VALUES (?, ?, '', 0)", id+1, url); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, visit := range []struct {
+		ID, URL, FromVisit, OpenerVisit int
+	}{
+		{1, 1, 0, 0},
+		{2, 2, 1, 0},
+		{3, 3, 0, 2},
+		{4, 4, 3, 0},
+		{5, 5, 4, 0},
+		{6, 2, 1, 0},
+		{7, 6, 8, 0},
+		{8, 7, 7, 0},
+	} {
+		if _, err := db.Exec("INSERT INTO visits (id, url, visit_time, from_visit, opener_visit) VALUES (?, ?, ?, ?, ?)", visit.ID, visit.URL, 13360000000000000+visit.ID, visit.FromVisit, visit.OpenerVisit); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return db
+}
+
+// formatTrail writes a trail as one line per visit, with its relation to its
+// parent.
+func formatTrail(root *TrailNode) string {
+	var lines []string
+	walkTrail(root, func(node *TrailNode, parent *TrailNode, depth int) {
+		line := fmt.Sprintf("%s%d %s", strings.Repeat("  ", depth), node.ID, node.Relation)
+		if node.Target {
+			line += " *"
+		}
+		lines = append(lines, strings.TrimSpace(line))
+	})
+
+	return strings.Join(lines, "\n")
+}
+
+func TestBuildTrail(t *testing.T) {
+	db := openTrailFixture(t)
+
+	for _, tc := range []struct {
+		Arg   string
+		Depth int
+		Want  string
+	}{
+		{"3", 5, "1\n2 link\n3 opener *\n4 link\n5 link"},
+		{"3", 1, "1\n2 link\n3 opener *\n4 link"},
+		{"3", 0, "1\n2 link\n3 opener *"},
+		// the latest visit of a url is picked
+		{"https://arc.net/", 1, "1\n6 link *"},
+		{"https://github.com/pomdtr/arc", 5, "1\n2 link\n3 opener\n4 link\n5 link *"},
+		// a cycle of referrers ends the walk
+		{"7", 5, "8\n7 link *"},
+	} {
+		t.Run(tc.Arg, func(t *testing.T) {
+			root, err := buildTrail(db, tc.Arg, tc.Depth)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := formatTrail(root); got != tc.Want {
+				t.Errorf("depth %d:\n%s\nwant:\n%s", tc.Depth, got, tc.Want)
+			}
+		})
+	}
+
+	if _, err := buildTrail(db, "https://missing.example.com/", 1); err == nil {
+		t.Error("building the trail of an unvisited url succeeded, want an error")
+	}
+}
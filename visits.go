@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cli/go-gh/v2/pkg/tableprinter"
+	sb "github.com/huandu/go-sqlbuilder"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// Core page transition types, stored in the lowest byte of visits.transition.
+var transitionTypes = []string{
+	"link",
+	"typed",
+	"auto_bookmark",
+	"auto_subframe",
+	"manual_subframe",
+	"generated",
+	"auto_toplevel",
+	"form_submit",
+	"reload",
+	"keyword",
+	"keyword_generated",
+}
+
+// Qualifiers stored in the upper bits of visits.transition.
+var transitionQualifiers = []struct {
+	Mask int64
+	Name string
+}{
+	{0x00800000, "blocked"},
+	{0x01000000, "forward_back"},
+	{0x02000000, "from_address_bar"},
+	{0x04000000, "home_page"},
+	{0x08000000, "from_api"},
+	{0x10000000, "chain_start"},
+	{0x20000000, "chain_end"},
+	{0x40000000, "client_redirect"},
+	{0x80000000, "server_redirect"},
+}
+
+const transitionCoreMask = 0xFF
+
+func transitionType(transition int64) string {
+	core := transition & transitionCoreMask
+	if core < int64(len(transitionTypes)) {
+		return transitionTypes[core]
+	}
+
+	return fmt.Sprintf("unknown(%d)", core)
+}
+
+func transitionQualifierNames(transition int64) []string {
+	var names []string
+	for _, qualifier := range transitionQualifiers {
+		if transition&qualifier.Mask != 0 {
+			names = append(names, qualifier.Name)
+		}
+	}
+
+	return names
+}
+
+func parseTransitionType(name string) (int, error) {
+	for i, transition := range transitionTypes {
+		if transition == name {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown transition: %s (valid: %s)", name, strings.Join(transitionTypes, ", "))
+}
+
+type Visit struct {
+	ID         int64     `json:"id"`
+	URL        string    `json:"url"`
+	Title      string    `json:"title"`
+	VisitedAt  time.Time `json:"visitedAt"`
+	Transition string    `json:"transition"`
+	Qualifiers []string  `json:"qualifiers"`
+	FromVisit  int64     `json:"fromVisit,omitempty"`
+	Referrer   string    `json:"referrer,omitempty"`
+}
+
+func NewCmdHistoryVisits() *cobra.Command {
+	var flags struct {
+		timeRange
+		url        string
+		transition string
+		limit      int
+		json       bool
+		jsonl      bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "visits",
+		Short: "List individual visits",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			db, cleanup, err := openHistory()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			query := sb.NewSelectBuilder()
+			query.Select("v.id", "u.url", "u.title", "v.visit_time", "v.transition", "COALESCE(v.from_visit, 0)", "COALESCE(r.url, '')")
+			query.From("visits v")
+			query.Join("urls u", "u.id = v.url")
+			query.JoinWithOption(sb.LeftJoin, "visits f", "f.id = v.from_visit")
+			query.JoinWithOption(sb.LeftJoin, "urls r", "r.id = f.url")
+			query.OrderBy("v.visit_time DESC")
+
+			if flags.limit > 0 {
+				query.Limit(flags.limit)
+			}
+
+			if flags.url != "" {
+				query.Where(likeCondition(query, "u.url", "%", flags.url, "%"))
+			}
+
+			if err := flags.timeRange.where(query, "v.visit_time"); err != nil {
+				return err
+			}
+
+			if flags.transition != "" {
+				core, err := parseTransitionType(flags.transition)
+				if err != nil {
+					return err
+				}
+				query.Where(fmt.Sprintf("(v.transition & %d) = %s", transitionCoreMask, query.Var(core)))
+			}
+
+			sql, sqlArgs := query.Build()
+			rows, err := db.Query(sql, sqlArgs...)
+			if err != nil {
+				return fmt.Errorf("failed to query: %w", err)
+			}
+			defer rows.Close()
+
+			var visits []Visit
+			for rows.Next() {
+				var visit Visit
+				var visitTime, transition int64
+				if err := rows.Scan(&visit.ID, &visit.URL, &visit.Title, &visitTime, &transition, &visit.FromVisit, &visit.Referrer); err != nil {
+					return fmt.Errorf("failed to scan: %w", err)
+				}
+
+				visit.VisitedAt = chromeTime(visitTime)
+				visit.Transition = transitionType(transition)
+				visit.Qualifiers = transitionQualifierNames(transition)
+				visits = append(visits, visit)
+			}
+
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("failed to query: %w", err)
+			}
+
+			if flags.jsonl {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetEscapeHTML(false)
+				for _, visit := range visits {
+					if err := encoder.Encode(visit); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			}
+
+			if flags.json {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				encoder.SetEscapeHTML(false)
+				return encoder.Encode(visits)
+			}
+
+			var printer tableprinter.TablePrinter
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				printer = tableprinter.New(os.Stdout, false, 0)
+			} else {
+				w, _, err := term.GetSize(int(os.Stdout.Fd()))
+				if err != nil {
+					return err
+				}
+
+				printer = tableprinter.New(os.Stdout, true, w)
+			}
+
+			printer.AddHeader([]string{"ID", "VisitedAt", "Transition", "URL", "Referrer"})
+			for _, visit := range visits {
+				printer.AddField(fmt.Sprintf("%d", visit.ID))
+				printer.AddField(visit.VisitedAt.Local().Format(time.DateTime))
+				printer.AddField(visit.Transition)
+				printer.AddField(visit.URL)
+				printer.AddField(visit.Referrer)
+				printer.EndRow()
+			}
+
+			return printer.Render()
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.url, "url", "", "only show visits of urls containing this string")
+	cmd.Flags().StringVar(&flags.transition, "transition", "", "only show visits with this transition type")
+	cmd.Flags().IntVarP(&flags.limit, "limit", "l", 100, "limit")
+	cmd.Flags().BoolVar(&flags.json, "json", false, "output as json")
+	cmd.Flags().BoolVar(&flags.jsonl, "jsonl", false, "output as json lines")
+	flags.timeRange.register(cmd)
+
+	return cmd
+}
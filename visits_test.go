@@ -0,0 +1,48 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTransitionDecoding(t *testing.T) {
+	for _, tc := range []struct {
+		Transition int64
+		Type       string
+		Qualifiers []string
+	}{
+		{0, "link", nil},
+		{0x32000001, "typed", []string{"from_address_bar", "chain_start", "chain_end"}},
+		{0x30000008, "reload", []string{"chain_start", "chain_end"}},
+		{0x4100000a, "keyword_generated", []string{"forward_back", "client_redirect"}},
+		// Chromium stores transitions as signed 32-bit ints, so server
+		// redirects are negative
+		{int64(int32(-0x70000000)), "link", []string{"chain_start", "server_redirect"}},
+		{42, "unknown(42)", nil},
+	} {
+		if got := transitionType(tc.Transition); got != tc.Type {
+			t.Errorf("transitionType(%#x) = %s, want %s", tc.Transition, got, tc.Type)
+		}
+
+		if got := transitionQualifierNames(tc.Transition); !slices.Equal(got, tc.Qualifiers) {
+			t.Errorf("transitionQualifierNames(%#x) = %v, want %v", tc.Transition, got, tc.Qualifiers)
+		}
+	}
+}
+
+func TestParseTransitionType(t *testing.T) {
+	for i, name := range transitionTypes {
+		core, err := parseTransitionType(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if core != i || transitionType(int64(core)) != name {
+			t.Errorf("parseTransitionType(%s) = %d, want %d", name, core, i)
+		}
+	}
+
+	if _, err := parseTransitionType("redirect"); err == nil {
+		t.Error("parsing an unknown transition succeeded, want an error")
+	}
+}